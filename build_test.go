@@ -2,12 +2,12 @@ package interfaces_test
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -24,52 +24,171 @@ func TestBuild(t *testing.T) {
 		t.Fatalf("MkdirAll()=%s", err)
 	}
 
+	bin := t.TempDir()
+
+	if p, err := exec.Command("go", "build", "-o", bin, "./cmd/interfacer", "./cmd/structer").CombinedOutput(); err != nil {
+		t.Fatalf("gobuild.Run()=%s:\n%s", err, p)
+	}
+
 	cases := map[string]struct {
-		run func(string) error
+		cmd   string   // command to run; interfacer if empty
+		args  []string // arguments of the command, besides -o
+		stdin string   // file in testdata piped to the command
+		err   string   // output of the command, if it is expected to fail
+		test  string   // file in testdata/build run as a test of the generated package
 	}{
 		"interfacer": {
-			run: func(base string) error {
-				args := []string{
-					"-for", `os.File`,
-					"-as", "interfacer.File",
-					"-o", filepath.Join(base, "package.go"),
-				}
-
-				p, err := exec.Command("interfacer", args...).CombinedOutput()
-				if err != nil {
-					return fmt.Errorf("%s:\n%s", err, p)
-				}
-
-				return nil
+			args: []string{
+				"-for", `os.File`,
+				"-as", "interfacer.File",
+			},
+		},
+		"generic": {
+			args: []string{
+				"-for", `sync/atomic.Pointer`,
+				"-as", "generic.Pointer",
+			},
+		},
+		"names": {
+			args: []string{
+				"-for", `net/http.Client`,
+				"-as", "names.Client",
+				"-names",
+				"-doc", "full",
+			},
+		},
+		"embedded": {
+			args: []string{
+				"-for", `io.ReadWriteCloser`,
+				"-as", "embedded.ReadWriter",
+				"-embed",
+				"-exclude", "Close",
+			},
+		},
+		"compact": {
+			args: []string{
+				"-for", `os.File`,
+				"-as", "compact.File",
+				"-compact",
+			},
+		},
+		"batch": {
+			args: []string{
+				"-for", `net/http.Client`,
+				"-as", "batch.Client",
+				"-for", `net/http.RoundTripper`,
+				"-as", "batch.RoundTripper",
+				"-for", `io.ReadCloser`,
+				"-as", "batch.ReadCloser",
+			},
+		},
+		"mock": {
+			args: []string{
+				"-for", `net/http.RoundTripper`,
+				"-as", "mock.RoundTripper",
+				"-for", `sync/atomic.Pointer`,
+				"-as", "mock.Pointer",
+				"-for", `log.Logger`,
+				"-as", "mock.Logger",
+				"-mock",
+			},
+			test: "mock_test.go",
+		},
+		"fake": {
+			args: []string{
+				"-for", `log.Logger`,
+				"-as", "fake.Logger",
+				"-for", `sync/atomic.Pointer`,
+				"-as", "fake.Pointer",
+				"-fake",
+				"-names",
+			},
+			test: "fake_test.go",
+		},
+		"record": {
+			args: []string{
+				"-for", `io.ReadWriteCloser`,
+				"-as", "record.ReadWriteCloser",
+				"-for", `sync/atomic.Pointer`,
+				"-as", "record.Pointer",
+				"-for", `log.Logger`,
+				"-as", "record.Logger",
+				"-record",
+			},
+			test: "record_test.go",
+		},
+		"slog": {
+			args: []string{
+				"-for", `database/sql.Conn`,
+				"-as", "slog.Conn",
+				"-for", `sync/atomic.Pointer`,
+				"-as", "slog.Pointer",
+				"-for", `log/slog.Handler`,
+				"-as", "slog.Handler",
+				"-for", `log/slog.LevelVar`,
+				"-as", "slog.LevelVar",
+				"-slog",
+				"-names",
+			},
+			test: "slog_test.go",
+		},
+		"retry": {
+			args: []string{
+				"-for", `database/sql.Conn`,
+				"-as", "retry.Conn",
+				"-for", `sync/atomic.Pointer`,
+				"-as", "retry.Pointer",
+				"-for", `net/http.RoundTripper`,
+				"-as", "retry.RoundTripper",
+				"-retry",
+			},
+			test: "retry_test.go",
+		},
+		"filters": {
+			args: []string{
+				"-for", `net/http.Client`,
+				"-as", "filters.Client",
+				"-for", `io.ReadCloser`,
+				"-as", "filters.ReadCloser",
+				"-exclude", "Do",
+				"-exclude", "Close*",
+			},
+		},
+		"unmatched": {
+			args: []string{
+				"-for", `net/http.Client`,
+				"-as", "unmatched.Client",
+				"-for", `io.ReadCloser`,
+				"-as", "unmatched.ReadCloser",
+				"-exclude", "Do",
+				"-exclude", "Close*",
+				"-exclude", "Typo",
+			},
+			err: `pattern "Typo" does not match any method`,
+		},
+		"warnings": {
+			args: []string{
+				"-for", `net/http.Client`,
+				"-as", "warnings.Client",
+				"-receiver", "value",
+			},
+			err: "methods not in the method set of Client",
+		},
+		"pointer": {
+			args: []string{
+				"-for", `net/http.Client`,
+				"-as", "pointer.Client",
+				"-receiver", "pointer",
 			},
 		},
 		"structer": {
-			run: func(base string) error {
-				testdata, err := ioutil.ReadFile(filepath.FromSlash("testdata/aws-billing.csv"))
-				if err != nil {
-					return err
-				}
-
-				args := []string{
-					"-tag", "json",
-					"-as", "structer.Record",
-					"-format", "csv",
-					"-o", filepath.Join(base, "package.go"),
-				}
-
-				var buf bytes.Buffer
-
-				cmd := exec.Command("structer", args...)
-				cmd.Stdin = bytes.NewReader(testdata)
-				cmd.Stdout = &buf
-				cmd.Stderr = &buf
-
-				if err := cmd.Run(); err != nil {
-					return fmt.Errorf("%s:\n%s", err, &buf)
-				}
-
-				return nil
+			args: []string{
+				"-tag", "json",
+				"-as", "structer.Record",
+				"-format", "csv",
 			},
+			cmd:   "structer",
+			stdin: "aws-billing.csv",
 		},
 	}
 
@@ -96,12 +215,43 @@ func TestBuild(t *testing.T) {
 				t.Fatalf("MkdirAll()=%s", err)
 			}
 
-			if err := cas.run(genpkg); err != nil {
-				t.Fatalf("run()=%s", err)
+			name, args := cas.cmd, append([]string(nil), cas.args...)
+			if name == "" {
+				name, args = "interfacer", append(args, "-nocache")
 			}
+			args = append(args, "-o", filepath.Join(genpkg, "package.go"))
 
 			var buf bytes.Buffer
 
+			cmd := exec.Command(filepath.Join(bin, name), args...)
+			cmd.Stdout = &buf
+			cmd.Stderr = &buf
+
+			if cas.stdin != "" {
+				f, err := os.Open(filepath.Join("testdata", cas.stdin))
+				if err != nil {
+					t.Fatalf("Open()=%s", err)
+				}
+				defer f.Close()
+				cmd.Stdin = f
+			}
+
+			err := cmd.Run()
+			if cas.err != "" {
+				if err == nil {
+					t.Fatalf("want error; got:\n%s", &buf)
+				}
+				if !strings.Contains(buf.String(), cas.err) {
+					t.Fatalf("want %q in output; got:\n%s", cas.err, &buf)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s.Run()=%s:\n%s", name, err, &buf)
+			}
+
+			buf.Reset()
+
 			if err := gocommand(&buf, pkg, "mod", "init").Run(); err != nil {
 				t.Fatalf("gomod.Run()=%s:\n%s", err, &buf)
 			}
@@ -111,6 +261,25 @@ func TestBuild(t *testing.T) {
 			if err := gocommand(&buf, pkg, "build", ".").Run(); err != nil {
 				t.Fatalf("gobuild.Run()=%s:\n%s", err, &buf)
 			}
+
+			if cas.test == "" {
+				return
+			}
+
+			test, err := ioutil.ReadFile(filepath.Join("testdata", "build", cas.test))
+			if err != nil {
+				t.Fatalf("ReadFile()=%s", err)
+			}
+
+			if err := ioutil.WriteFile(filepath.Join(genpkg, "package_test.go"), test, 0644); err != nil {
+				t.Fatalf("WriteFile()=%s", err)
+			}
+
+			buf.Reset()
+
+			if err := gocommand(&buf, pkg, "test", ".").Run(); err != nil {
+				t.Fatalf("gotest.Run()=%s:\n%s", err, &buf)
+			}
 		})
 	}
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/rjeczalik/interfaces"
+)
+
+// cacheVersion is bumped whenever the format or content of cached entries
+// changes.
+const cacheVersion = "2"
+
+// cache is an on-disk cache of built interfaces. Entries are keyed by
+// a hash of a query, options and source files of the packages the
+// interface is built from, so they never need to be invalidated.
+type cache struct {
+	dir string
+}
+
+// cached is a cache entry.
+type cached struct {
+	Interface interfaces.Interface `json:"interface"`
+	Warnings  []string             `json:"warnings,omitempty"`  // warnings reported while building the interface
+	Unmatched []string             `json:"unmatched,omitempty"` // -include and -exclude patterns matching no method
+}
+
+func newCache() (*cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &cache{dir: filepath.Join(dir, "interfacer")}, nil
+}
+
+// keys gives cache keys for the options. Besides the options, they cover
+// source files the interfaces are built from; see interfaces.SourceHashes.
+func (c *cache) keys(opts []*interfaces.Options) ([]string, error) {
+	hashes, err := interfaces.SourceHashes(opts...)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(opts))
+	for i, o := range opts {
+		h := sha256.New()
+		if err := writeOptions(h, o); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(h, hashes[i])
+		keys[i] = hex.EncodeToString(h.Sum(nil))
+	}
+	return keys, nil
+}
+
+// writeOptions writes everything, besides source files, the interface built
+// for the options depends on.
+func writeOptions(w io.Writer, o *interfaces.Options) error {
+	fmt.Fprintln(w, "interfacer", cacheVersion, runtime.Version(), build.Default.GOROOT)
+	for _, env := range []string{"GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS", "GOWORK", "GOEXPERIMENT"} {
+		fmt.Fprintf(w, "%s=%s\n", env, os.Getenv(env))
+	}
+	key := struct {
+		Query        *interfaces.Query
+		Unexported   bool
+		Comments     bool
+		Receiver     interfaces.Receiver
+		Include      []string
+		Exclude      []string
+		KeepEmbedded bool
+		Compact      []string
+		Strict       bool
+	}{o.Query, o.Unexported, o.Comments, o.Receiver, o.Include, o.Exclude, o.KeepEmbedded, o.Compact, o.Strict}
+	return json.NewEncoder(w).Encode(key)
+}
+
+func (c *cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get gives a cached entry, if there is one.
+func (c *cache) get(key string) (*cached, bool) {
+	p, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	v := new(cached)
+	if err := json.Unmarshal(p, v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// put stores the entry; it is written to a temporary file first, so
+// concurrent runs never read partially written ones.
+func (c *cache) put(key string, v *cached) error {
+	p, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	name := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), key+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(p)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// clear removes all the cached entries.
+func (c *cache) clear() error {
+	return os.RemoveAll(c.dir)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	register("fake", "Generate also a fake implementing each interface with a func field per method.", fakeGen{})
+}
+
+type fakeGen struct{}
+
+func (fakeGen) deps() []string {
+	return nil
+}
+
+func (fakeGen) appendTemplate(v *vars, w io.Writer) error {
+	fields := make([]string, len(v.Interface.Methods))
+	for i, fn := range v.Interface.Methods {
+		fields[i] = fn.Name + "Func"
+	}
+	if err := checkClash(v.Interface.Methods, fields...); err != nil {
+		return fmt.Errorf("unable to generate fake for %s: %w", v.InterfaceName, err)
+	}
+	return fakeTmpl.Execute(w, v)
+}
+
+// Args gives the parameters as arguments of a call of the function, with
+// a variadic one passed through with "...".
+func (s signature) Args() string {
+	names := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		names[i] = p.Name
+	}
+	if s.Variadic {
+		names[len(names)-1] += "..."
+	}
+	return strings.Join(names, ", ")
+}
+
+var fakeTmpl = mustTemplate(`{{with $v := .}}{{$f := printf "%sFake" $v.InterfaceName}}{{$tn := $v.Interface.TypeParams.Names}}
+// {{$f}} is a fake implementation of {{$v.InterfaceName}}. Each of its methods
+// calls the corresponding func field, or panics if it is nil.
+type {{$f}}{{$v.Interface.TypeParams}} struct {
+{{range $v.Interface.Methods}}	{{.Name}}Func {{(signature .).FuncType}}
+{{end}}}
+{{if not $v.Interface.TypeParams}}
+var _ {{$v.InterfaceName}} = (*{{$f}})(nil)
+{{end}}{{range $v.Interface.Methods}}{{$s := signature . "f"}}
+// {{.Name}} calls {{.Name}}Func.
+func (f *{{$f}}{{$tn}}) {{$s}} {
+	if f.{{.Name}}Func == nil {
+		panic("{{$f}}: {{.Name}} called, but {{.Name}}Func is nil")
+	}
+	{{if $s.Results}}return {{end}}f.{{.Name}}Func({{$s.Args}})
+}
+{{end}}{{end}}`)
@@ -6,8 +6,13 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	godoc "go/doc"
 	"go/format"
+	"io"
 	"os"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -15,32 +20,268 @@ import (
 )
 
 var (
-	query  = flag.String("for", "", "Type to generate an interface for.")
-	as     = flag.String("as", "main.Interface", `Generated interface name.`)
-	output = flag.String("o", "-", "Output file.")
-	all    = flag.Bool("all", false, "Include also unexported methods.")
+	output   = flag.String("o", "-", "Output file.")
+	manifest = flag.String("manifest", "", "File with -for, -as and optionally -o values, one triple per line.")
+	nocache  = flag.Bool("nocache", false, "Do not use the on-disk cache of generated interfaces.")
+	clearc   = flag.Bool("clearcache", false, "Remove the on-disk cache of generated interfaces and exit.")
+	all      = flag.Bool("all", false, "Include also unexported methods.")
+	names    = flag.Bool("names", false, "Include parameter and result names.")
+	embed    = flag.Bool("embed", false, "Keep embedded interfaces instead of flattening their methods.")
+	strict   = flag.Bool("strict", false, "Fail if method signatures refer to types which failed to type-check.")
+	compact  = flag.Bool("compact", false, "Embed well-known interfaces in place of the methods they consist of.")
+	doc      = flag.String("doc", "", `Include method doc comments: "full" or "synopsis" for the first sentence only.`)
 )
 
-var tmpl = template.Must(template.New("").Parse(`// Code generated by interfacer; DO NOT EDIT
+var (
+	query      stringList
+	as         stringList
+	receiver   interfaces.Receiver
+	include    stringList
+	exclude    stringList
+	compactPkg stringList
+)
 
-package {{.PackageName}}
+func init() {
+	flag.Var(&query, "for", "Type to generate an interface for. Can be repeated.")
+	flag.Var(&as, "as", `Generated interface name; "main.Interface" if not set. Can be repeated, once per -for.`)
+	flag.TextVar(&receiver, "receiver", interfaces.ReceiverAny,
+		`Method set to generate an interface for: "value" (T), "pointer" (*T) or "any" (both); ignored for interface types.`)
+	flag.Var(&include, "include", "Include only methods matching the pattern; a glob or a /regexp/. Can be repeated; applies to all -for types.")
+	flag.Var(&exclude, "exclude", "Exclude methods matching the pattern; a glob or a /regexp/. Can be repeated; applies to all -for types.")
+	flag.Var(&compactPkg, "compact-pkg", "Package of interfaces to use with -compact; "+
+		strings.Join(interfaces.DefaultCompact, ", ")+" if not set. Can be repeated.")
+}
+
+// stringList is a repeatable flag value.
+type stringList []string
 
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var tmpl = mustTemplate(`// Code generated by interfacer; DO NOT EDIT
+
+package {{.PackageName}}
+{{if .Imports}}
 import (
-{{range .Deps}}	"{{.}}"
+{{range .Imports}}	{{.}}
 {{end}})
-
+{{end}}{{range .Interfaces}}
 // {{.InterfaceName}} is an interface generated for {{.Type}}.
-type {{.InterfaceName}} interface {
-{{range .Interface}}	{{.}}
+type {{.InterfaceName}}{{.Interface.TypeParams}} interface {
+{{range .Interface.Embeds}}	{{.}}
+{{end}}{{range .Interface.Methods}}{{comment .Doc}}	{{if $.Names}}{{.NamedString}}{{else}}{{.}}{{end}}
 {{end}}}
-`))
+{{end}}`)
+
+var tmplFuncs = template.FuncMap{
+	"comment":   comment,
+	"schema":    callSchema,
+	"signature": newSignature,
+}
+
+func mustTemplate(content string) *template.Template {
+	return template.Must(template.New("").Funcs(tmplFuncs).Parse(content))
+}
+
+// comment formats a doc comment text for a method according to the -doc flag.
+func comment(text string) string {
+	switch *doc {
+	case "full":
+	case "synopsis":
+		text = new(godoc.Package).Synopsis(text)
+	default:
+		return ""
+	}
+	var buf strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line == "" {
+			buf.WriteString("\t//\n")
+		} else {
+			buf.WriteString("\t// " + line + "\n")
+		}
+	}
+	if text == "" {
+		return ""
+	}
+	return buf.String()
+}
+
+// generator is a helper interface used to generate code that goes along with
+// a generated interface, e.g. a mock implementing it.
+type generator interface {
+	deps() []string
+	appendTemplate(*vars, io.Writer) error
+}
+
+// generators map holds all registered generators by their flag names.
+var generators = make(map[string]generator)
+
+// enabled map holds values of the flags enabling generators.
+var enabled = make(map[string]*bool)
+
+// register adds the generator, which is enabled with the flag of the given name.
+func register(name, usage string, g generator) {
+	generators[name] = g
+	enabled[name] = flag.Bool(name, false, usage)
+}
+
+// enabledGenerators gives names of the generators enabled with flags, sorted.
+func enabledGenerators() []string {
+	var names []string
+	for name, ok := range enabled {
+		if *ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkClash fails if any of the methods is named like one of the reserved
+// names, e.g. of methods and fields of a generated type.
+func checkClash(methods []interfaces.Func, reserved ...string) error {
+	for _, fn := range methods {
+		for _, name := range reserved {
+			if fn.Name == name {
+				return fmt.Errorf("method %s clashes with a method or field of the generated code", name)
+			}
+		}
+	}
+	return nil
+}
+
+// signature describes a method of a generated interface, which parameters and
+// results are all given unique names, for use by generators.
+type signature struct {
+	Name     string
+	Params   []param
+	Results  []param
+	Variadic bool // whether the last parameter is variadic
+
+	fn interfaces.Func
+}
+
+// param is a named parameter or result of a signature.
+type param struct {
+	Name string
+	Key  string // declared name of the parameter, e.g. arg0 if it has none
+	Type string // type of the parameter; a slice for a variadic one
+}
+
+// newSignature gives a signature of the function. Parameters and results keep
+// their names, unless they are blank, missing or are among the reserved ones,
+// e.g. names of local variables of the generated code.
+func newSignature(fn interfaces.Func, reserved ...string) signature {
+	used := make(map[string]bool)
+	for _, name := range reserved {
+		used[name] = true
+	}
+	params := func(types []interfaces.Type, names []string, prefix string) []param {
+		list := make([]param, len(types))
+		for i, typ := range types {
+			key := prefix + strconv.Itoa(i)
+			if i < len(names) && names[i] != "" {
+				key = names[i]
+			}
+			name := key
+			for n := 2; used[name]; n++ {
+				name = key + strconv.Itoa(n)
+			}
+			used[name] = true
+			list[i] = param{Name: name, Key: key, Type: typ.String()}
+		}
+		return list
+	}
+	return signature{
+		Name:     fn.Name,
+		Params:   params(fn.Ins, fn.InNames, "arg"),
+		Results:  params(fn.Outs, fn.OutNames, "res"),
+		Variadic: fn.IsVariadic,
+		fn:       fn,
+	}
+}
+
+// String gives Go code representation of the signature with named parameters
+// and results, as used in a method declaration.
+func (s signature) String() string {
+	var buf strings.Builder
+	buf.WriteString(s.Name + "(")
+	for i, p := range s.Params {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		if typ := p.Type; s.Variadic && i == len(s.Params)-1 {
+			buf.WriteString(p.Name + " ..." + strings.TrimPrefix(typ, "[]"))
+		} else {
+			buf.WriteString(p.Name + " " + typ)
+		}
+	}
+	buf.WriteString(")")
+	if len(s.Results) != 0 {
+		buf.WriteString(" (")
+		for i, r := range s.Results {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(r.Name + " " + r.Type)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String()
+}
+
+// FuncType gives Go code representation of the signature as a func type,
+// e.g. func(p []byte) (n int, err error). Only the original names of
+// parameters and results are kept.
+func (s signature) FuncType() string {
+	return "func" + strings.TrimPrefix(s.fn.NamedString(), s.fn.Name)
+}
+
+// Context gives the name of the first parameter, if it is a context.Context.
+func (s signature) Context() string {
+	if len(s.Params) != 0 && isType(s.fn.Ins[0], "context", "Context") {
+		return s.Params[0].Name
+	}
+	return ""
+}
+
+// Err gives the name of the last result, if it is an error.
+func (s signature) Err() string {
+	if n := len(s.Results); n != 0 && isType(s.fn.Outs[n-1], "", "error") {
+		return s.Results[n-1].Name
+	}
+	return ""
+}
+
+func isType(typ interfaces.Type, importPath, name string) bool {
+	return typ.Kind == interfaces.KindNamed && typ.ImportPath == importPath && typ.Name == name && len(typ.TypeArgs) == 0
+}
+
+// file holds template variables of a generated file.
+type file struct {
+	PackageName string
+	Imports     []interfaces.Import
+	Names       bool
+	Interfaces  []*vars
+}
 
 type vars struct {
-	PackageName   string
 	InterfaceName string
 	Type          string
-	Deps          []string
 	Interface     interfaces.Interface
+
+	imports *interfaces.ImportSet
+}
+
+// Pkg gives the name a package with the given import path is imported with.
+func (v *vars) Pkg(path string) string {
+	return v.imports.Name(path)
 }
 
 func main() {
@@ -50,48 +291,250 @@ func main() {
 	}
 }
 
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "interfacer: warning: "+format+"\n", args...)
+}
+
 func run() error {
 	flag.Parse()
-	if *query == "" {
-		return errors.New("empty -for flag value; see -help for details")
+	if *clearc {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+		return c.clear()
 	}
 	if *output == "" {
 		return errors.New("empty -o flag value; see -help for details")
 	}
-	q, err := interfaces.ParseQuery(*query)
+	if *doc != "" && *doc != "full" && *doc != "synopsis" {
+		return errors.New("invalid -doc flag value; see -help for details")
+	}
+	gens := enabledGenerators()
+	if len(gens) != 0 && (*embed || *compact) {
+		return fmt.Errorf("-%s flag can't be used with -embed or -compact; see -help for details", gens[0])
+	}
+	if len(as) == 0 && len(query) == 1 {
+		as = stringList{"main.Interface"}
+	}
+	if len(as) != len(query) {
+		return errors.New("-for and -as flags must be given in pairs; see -help for details")
+	}
+	var entries []entry
+	for i := range query {
+		entries = append(entries, entry{query: query[i], as: as[i], output: *output})
+	}
+	if *manifest != "" {
+		m, err := readManifest(*manifest, *output)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, m...)
+	}
+	if len(entries) == 0 {
+		return errors.New("empty -for flag value; see -help for details")
+	}
+	opts := make([]*interfaces.Options, len(entries))
+	for i, e := range entries {
+		q, err := interfaces.ParseQuery(e.query)
+		if err != nil {
+			return err
+		}
+		opts[i] = &interfaces.Options{
+			Query:        q,
+			Unexported:   *all,
+			Comments:     *doc != "",
+			Receiver:     receiver,
+			Include:      include,
+			Exclude:      exclude,
+			KeepEmbedded: *embed,
+			Strict:       *strict,
+		}
+		if *compact {
+			opts[i].Compact = interfaces.DefaultCompact
+			if len(compactPkg) != 0 {
+				opts[i].Compact = compactPkg
+			}
+		}
+	}
+	inters, err := buildInterfaces(opts)
 	if err != nil {
 		return err
 	}
-	opts := &interfaces.Options{
-		Query:      q,
-		Unexported: *all,
+	var outputs []string
+	files := make(map[string]*file)
+	for i, e := range entries {
+		pkg, name := "", e.as
+		if i := strings.IndexRune(e.as, '.'); i != -1 {
+			pkg, name = e.as[:i], e.as[i+1:]
+		}
+		f, ok := files[e.output]
+		if !ok {
+			f = &file{PackageName: pkg, Names: *names}
+			files[e.output] = f
+			outputs = append(outputs, e.output)
+		}
+		if f.PackageName != pkg {
+			return fmt.Errorf("%s: interfaces %s.%s and %s must be in the same package",
+				e.output, f.PackageName, f.Interfaces[0].InterfaceName, e.as)
+		}
+		for _, v := range f.Interfaces {
+			if v.InterfaceName == name {
+				return fmt.Errorf("%s: interface %s generated more than once", e.output, e.as)
+			}
+		}
+		f.Interfaces = append(f.Interfaces, &vars{
+			InterfaceName: name,
+			Type:          fmt.Sprintf(`"%s"`, e.query),
+			Interface:     inters[i],
+		})
 	}
-	i, err := interfaces.NewWithOptions(opts)
+	for _, output := range outputs {
+		if err := write(output, files[output], gens); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildInterfaces builds the interfaces, using the on-disk cache unless -nocache
+// is given. Warnings are reported also for interfaces read from the cache.
+// All the options are expected to share -include and -exclude patterns.
+func buildInterfaces(opts []*interfaces.Options) ([]interfaces.Interface, error) {
+	var (
+		c    *cache
+		keys []string
+		err  error
+	)
+	if !*nocache {
+		if c, err = newCache(); err == nil {
+			keys, err = c.keys(opts)
+		}
+		if err != nil {
+			warnf("cache disabled: %s", err)
+			c = nil
+		}
+	}
+	var (
+		entries = make([]*cached, len(opts))
+		missing []int // indexes of entries not found in the cache
+		batch   []*interfaces.Options
+	)
+	for i, o := range opts {
+		if c != nil {
+			if v, ok := c.get(keys[i]); ok {
+				entries[i] = v
+				continue
+			}
+		}
+		v := new(cached)
+		o.Warnf = func(format string, args ...interface{}) {
+			v.Warnings = append(v.Warnings, fmt.Sprintf(format, args...))
+		}
+		o.Unmatched = func(pattern string) {
+			v.Unmatched = append(v.Unmatched, pattern)
+		}
+		entries[i] = v
+		missing = append(missing, i)
+		batch = append(batch, o)
+	}
+	inters, err := interfaces.NewBatch(batch...)
 	if err != nil {
-		return err
+		// Warnings reported so far may explain the failure.
+		report(entries)
+		return nil, err
+	}
+	for j, i := range missing {
+		entries[i].Interface = inters[j]
+		if c != nil {
+			if err := c.put(keys[i], entries[i]); err != nil {
+				warnf("unable to cache interface for %s: %s", opts[i].Query.TypeName, err)
+			}
+		}
 	}
-	v := &vars{
-		Type:      fmt.Sprintf(`"%s"`, *query),
-		Deps:      i.Deps(),
-		Interface: i,
+	report(entries)
+	result := make([]interfaces.Interface, len(opts))
+	for i, v := range entries {
+		result[i] = v.Interface
 	}
-	if i := strings.IndexRune(*as, '.'); i != -1 {
-		v.PackageName = (*as)[:i]
-		v.InterfaceName = (*as)[i+1:]
-	} else {
-		v.InterfaceName = *as
+	// Patterns are shared by all the types, so a pattern fails only if it
+	// matches no method of any of them.
+	unmatched := make(map[string]int)
+	for _, v := range entries {
+		for p := range setOf(v.Unmatched) {
+			unmatched[p]++
+		}
+	}
+	for _, p := range append(opts[0].Include[:len(opts[0].Include):len(opts[0].Include)], opts[0].Exclude...) {
+		if unmatched[p] == len(opts) {
+			return nil, fmt.Errorf("pattern %q does not match any method", p)
+		}
 	}
+	return result, nil
+}
+
+// report prints warnings and diagnostics of the entries.
+func report(entries []*cached) {
+	printed := make(map[string]bool)
+	for _, v := range entries {
+		for _, w := range v.Warnings {
+			warnf("%s", w)
+		}
+		// Interfaces built from the same package share its diagnostics.
+		for _, d := range v.Interface.Diagnostics {
+			if s := d.String(); !printed[s] {
+				fmt.Fprintln(os.Stderr, "interfacer:", s)
+				printed[s] = true
+			}
+		}
+	}
+}
+
+func setOf(list []string) map[string]bool {
+	m := make(map[string]bool, len(list))
+	for _, s := range list {
+		m[s] = true
+	}
+	return m
+}
+
+// write generates the file, together with the code of the given generators
+// for each of its interfaces, and writes it to the output, which is either
+// a file path or - for standard output.
+func write(output string, v *file, gens []string) error {
+	reserved := []string{v.PackageName}
+	for _, i := range v.Interfaces {
+		reserved = append(reserved, i.InterfaceName)
+	}
+	imports := interfaces.NewImportSet(reserved...)
+	for _, name := range gens {
+		for _, dep := range generators[name].deps() {
+			imports.Add(dep, path.Base(dep))
+		}
+	}
+	for _, i := range v.Interfaces {
+		imports.AddInterface(&i.Interface)
+		i.imports = imports
+	}
+	v.Imports = imports.Resolve()
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, v); err != nil {
 		return err
 	}
+	for _, i := range v.Interfaces {
+		for _, name := range gens {
+			if err := generators[name].appendTemplate(i, &buf); err != nil {
+				return err
+			}
+		}
+	}
 	formatted, err := format.Source(buf.Bytes())
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to format generated code for %s: %s", output, err)
 	}
 	f := os.Stdout
-	if *output != "-" {
-		f, err = os.OpenFile(*output, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+	if output != "-" {
+		f, err = os.OpenFile(output, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// entry describes a single interface to generate.
+type entry struct {
+	query  string // value of -for
+	as     string // value of -as
+	output string // value of -o
+}
+
+// readManifest reads entries from the manifest file. Each line holds
+// whitespace-separated values of -for, -as and optionally -o flags, the
+// latter being output if empty. Blank lines and lines starting with #
+// are ignored, e.g.:
+//
+//	# Interfaces for tests.
+//	os.File mock.File mock/file.go
+//	net/http.Client mock.Client mock/client.go
+//	net/http.RoundTripper mock.RoundTripper mock/client.go
+func readManifest(name, output string) ([]entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []entry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 2:
+			entries = append(entries, entry{query: fields[0], as: fields[1], output: output})
+		case 3:
+			entries = append(entries, entry{query: fields[0], as: fields[1], output: fields[2]})
+		default:
+			return nil, fmt.Errorf("%s:%d: want 2 or 3 fields, got %d", name, n, len(fields))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func init() {
+	register("mock", "Generate also a mock implementing each interface.", mockGen{})
+}
+
+type mockGen struct{}
+
+func (mockGen) deps() []string {
+	return []string{"fmt", "reflect", "sync", "testing"}
+}
+
+// mockMethods are methods of a generated mock, which must not clash with
+// methods of the interface.
+var mockMethods = []string{"On", "Calls", "AssertExpectations", "called"}
+
+func (mockGen) appendTemplate(v *vars, w io.Writer) error {
+	if err := checkClash(v.Interface.Methods, mockMethods...); err != nil {
+		return fmt.Errorf("unable to generate mock for %s: %w", v.InterfaceName, err)
+	}
+	return mockTmpl.Execute(w, v)
+}
+
+var mockTmpl = mustTemplate(`{{with $v := .}}{{$m := printf "%sMock" $v.InterfaceName}}{{$tn := $v.Interface.TypeParams.Names}}
+// {{$m}} is a mock implementation of {{$v.InterfaceName}}. Calls of its methods
+// are matched against expectations set with On and recorded.
+type {{$m}}{{$v.Interface.TypeParams}} struct {
+	mu       {{$v.Pkg "sync"}}.Mutex
+	expected []*{{$m}}Call
+	calls    []{{$m}}Call
+}
+{{if not $v.Interface.TypeParams}}
+var _ {{$v.InterfaceName}} = (*{{$m}})(nil)
+{{end}}
+// {{$m}}Call is a call of a method of {{$m}}, either an expected or a recorded one.
+type {{$m}}Call struct {
+	Method  string // name of the method
+	Args    []any  // arguments of the call; a variadic argument is a single slice
+	Returns []any  // values returned by the call
+
+	times int // expected number of calls; 0 for at least one
+	count int // number of calls matched so far
+}
+
+// Return sets the values returned by the call. Missing or nil values are
+// returned as zero values.
+func (c *{{$m}}Call) Return(values ...any) *{{$m}}Call {
+	c.Returns = values
+	return c
+}
+
+// Times sets the expected number of calls; by default the call is expected
+// at least once.
+func (c *{{$m}}Call) Times(n int) *{{$m}}Call {
+	c.times = n
+	return c
+}
+
+// Once is a shorthand for Times(1).
+func (c *{{$m}}Call) Once() *{{$m}}Call {
+	return c.Times(1)
+}
+
+func (c *{{$m}}Call) ret(i int) any {
+	if i < len(c.Returns) {
+		return c.Returns[i]
+	}
+	return nil
+}
+
+// On adds an expectation of a call of the method with the given arguments,
+// which are compared with reflect.DeepEqual; if none are given, calls with
+// any arguments match. Expectations are matched in the order they were added,
+// skipping ones which were already called the expected number of times.
+func (m *{{$m}}{{$tn}}) On(method string, args ...any) *{{$m}}Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &{{$m}}Call{Method: method, Args: args}
+	m.expected = append(m.expected, c)
+	return c
+}
+
+// Calls gives the calls recorded so far.
+func (m *{{$m}}{{$tn}}) Calls() []{{$m}}Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]{{$m}}Call(nil), m.calls...)
+}
+
+// AssertExpectations reports an error for each expected call, which was not
+// called the expected number of times.
+func (m *{{$m}}{{$tn}}) AssertExpectations(t {{$v.Pkg "testing"}}.TB) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.expected {
+		switch {
+		case c.times == 0 && c.count == 0:
+			t.Errorf("{{$m}}: expected call %s%v was not made", c.Method, c.Args)
+		case c.times != 0 && c.count != c.times:
+			t.Errorf("{{$m}}: expected call %s%v %d time(s); got %d", c.Method, c.Args, c.times, c.count)
+		}
+	}
+}
+
+// called records the call and gives the expectation it matched. It panics
+// if the call was not expected.
+func (m *{{$m}}{{$tn}}) called(method string, args ...any) *{{$m}}Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.expected {
+		if c.Method != method || c.times != 0 && c.count == c.times {
+			continue
+		}
+		if len(c.Args) != 0 && !{{$v.Pkg "reflect"}}.DeepEqual(c.Args, args) {
+			continue
+		}
+		c.count++
+		m.calls = append(m.calls, {{$m}}Call{Method: method, Args: args, Returns: c.Returns})
+		return c
+	}
+	panic({{$v.Pkg "fmt"}}.Sprintf("{{$m}}: unexpected call %s%v", method, args))
+}
+{{range $v.Interface.Methods}}{{$s := signature . "m" "c" "r"}}
+// {{.Name}} implements {{$v.InterfaceName}}.
+func (m *{{$m}}{{$tn}}) {{$s}} {
+	{{if $s.Results}}c := {{end}}m.called("{{.Name}}"{{range $s.Params}}, {{.Name}}{{end}})
+{{range $i, $r := $s.Results}}	if r := c.ret({{$i}}); r != nil {
+		{{$r.Name}} = r.({{$r.Type}})
+	}
+{{end}}{{if $s.Results}}	return
+{{end}}}
+{{end}}{{end}}`)
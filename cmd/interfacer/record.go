@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rjeczalik/interfaces"
+)
+
+func init() {
+	register("record", "Generate also a recorder, which writes calls of an implementation of each interface "+
+		"to a JSON call log, and a replayer of such logs.", recordGen{})
+}
+
+type recordGen struct{}
+
+func (recordGen) deps() []string {
+	return []string{"bytes", "encoding/json", "errors", "fmt", "os", "sync"}
+}
+
+// recordMethods are methods and fields of a generated recorder and replayer,
+// which must not clash with methods of the interface.
+var recordMethods = []string{"Save", "Unreplayed", "call", "encode", "errMessage", "impl", "record", "replay", "result", "resultErr"}
+
+// recordVars holds template variables of a recorder and replayer.
+type recordVars struct {
+	*vars
+	Skipped      string          // comma-separated methods, which are not recorded
+	Unreplayable map[string]bool // methods, which results cannot be replayed
+}
+
+func (recordGen) appendTemplate(v *vars, w io.Writer) error {
+	rv := &recordVars{vars: v, Unreplayable: make(map[string]bool)}
+	var skipped []string
+	if err := checkClash(v.Interface.Methods, recordMethods...); err != nil {
+		return fmt.Errorf("unable to generate recorder for %s: %w", v.InterfaceName, err)
+	}
+	for _, fn := range v.Interface.Methods {
+		for _, typ := range fn.Outs {
+			if !decodable(typ) {
+				warnf("method %s of %s is not recorded, since its result %s cannot be decoded from JSON",
+					fn.Name, v.InterfaceName, typ)
+				rv.Unreplayable[fn.Name] = true
+				skipped = append(skipped, fn.Name)
+				break
+			}
+		}
+	}
+	rv.Skipped = strings.Join(skipped, ", ")
+	return recordTmpl.Execute(w, rv)
+}
+
+// decodable tells whether a result of the type can be replayed by decoding
+// it from JSON. Values of interface, func and chan types cannot, with the
+// exception of errors, which are replayed from their messages. Fields of
+// named struct types are not checked.
+func decodable(typ interfaces.Type) bool {
+	switch typ.Kind {
+	case interfaces.KindNamed:
+		if isType(typ, "", "error") {
+			return true
+		}
+		switch typ.Underlying {
+		case interfaces.KindInterface, interfaces.KindFunc, interfaces.KindChan:
+			return false
+		}
+		return true
+	case interfaces.KindPointer, interfaces.KindSlice, interfaces.KindArray:
+		return decodable(*typ.Elem)
+	case interfaces.KindMap:
+		return decodable(*typ.Key) && decodable(*typ.Elem)
+	case interfaces.KindStruct:
+		for _, f := range typ.Fields {
+			if !decodable(f.Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// callSchema gives a Go string literal with JSON representation of the
+// methods, which is stored in call logs, so that replaying a log recorded
+// for a different interface fails. Types are identified by import paths
+// only, so the schema does not depend on names of imports.
+func callSchema(methods []interfaces.Func) (string, error) {
+	methods = schemaFuncs(methods)
+	p, err := json.Marshal(methods)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Quote(string(p)), nil
+}
+
+func schemaFuncs(list []interfaces.Func) []interfaces.Func {
+	if list == nil {
+		return nil
+	}
+	funcs := make([]interfaces.Func, len(list))
+	for i, fn := range list {
+		fn.Doc = ""
+		fn.Pos = token.Position{}
+		fn.Ins = schemaTypes(fn.Ins)
+		fn.Outs = schemaTypes(fn.Outs)
+		funcs[i] = fn
+	}
+	return funcs
+}
+
+func schemaTypes(list []interfaces.Type) []interfaces.Type {
+	if list == nil {
+		return nil
+	}
+	types := make([]interfaces.Type, len(list))
+	for i, typ := range list {
+		types[i] = schemaType(typ)
+	}
+	return types
+}
+
+// schemaType gives a copy of the type with names of packages cleared.
+func schemaType(typ interfaces.Type) interfaces.Type {
+	typ.Package = ""
+	if typ.Elem != nil {
+		elem := schemaType(*typ.Elem)
+		typ.Elem = &elem
+	}
+	if typ.Key != nil {
+		key := schemaType(*typ.Key)
+		typ.Key = &key
+	}
+	if typ.Func != nil {
+		fn := schemaFuncs([]interfaces.Func{*typ.Func})[0]
+		typ.Func = &fn
+	}
+	if typ.Fields != nil {
+		fields := make([]interfaces.Field, len(typ.Fields))
+		for i, f := range typ.Fields {
+			f.Type = schemaType(f.Type)
+			fields[i] = f
+		}
+		typ.Fields = fields
+	}
+	typ.TypeArgs = schemaTypes(typ.TypeArgs)
+	typ.Methods = schemaFuncs(typ.Methods)
+	typ.Embeds = schemaTypes(typ.Embeds)
+	typ.Terms = schemaTypes(typ.Terms)
+	return typ
+}
+
+// ResultNames gives comma-separated names of the results.
+func (s signature) ResultNames() string {
+	names := make([]string, len(s.Results))
+	for i, r := range s.Results {
+		names[i] = r.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+var recordTmpl = mustTemplate(`{{with $v := .}}{{$i := $v.InterfaceName}}{{$tp := $v.Interface.TypeParams}}{{$tn := $v.Interface.TypeParams.Names}}{{$json := $v.Pkg "encoding/json"}}{{$bytes := $v.Pkg "bytes"}}{{$fmt := $v.Pkg "fmt"}}{{$os := $v.Pkg "os"}}
+// {{$i}}CallSchema describes the methods of {{$i}}, as stored in call logs.
+var {{$i}}CallSchema = {{schema $v.Interface.Methods}}
+
+// {{$i}}CallLog is a log of calls of methods of {{$i}}.
+type {{$i}}CallLog struct {
+	Methods {{$json}}.RawMessage ` + "`" + `json:"methods"` + "`" + `
+	Calls   []{{$i}}Call        ` + "`" + `json:"calls"` + "`" + `
+}
+
+// {{$i}}Call is a single call of a method of {{$i}}. Arguments and results
+// are JSON-encoded; error results are encoded as their messages.
+type {{$i}}Call struct {
+	Method  string            ` + "`" + `json:"method"` + "`" + `
+	Args    []{{$json}}.RawMessage ` + "`" + `json:"args,omitempty"` + "`" + `
+	Results []{{$json}}.RawMessage ` + "`" + `json:"results,omitempty"` + "`" + `
+}
+
+// {{$i}}Recorder is an implementation of {{$i}}, which delegates calls to
+// another one and records them.{{if $v.Skipped}}
+//
+// Methods passed through without recording, since their results cannot be
+// replayed: {{$v.Skipped}}.{{end}}
+type {{$i}}Recorder{{$tp}} struct {
+	impl  {{$i}}{{$tn}}
+	mu    {{$v.Pkg "sync"}}.Mutex
+	calls []{{$i}}Call
+	err   error
+}
+{{if not $tp}}
+var _ {{$i}} = (*{{$i}}Recorder)(nil)
+{{end}}
+// New{{$i}}Recorder gives a recorder of calls of impl.
+func New{{$i}}Recorder{{$tp}}(impl {{$i}}{{$tn}}) *{{$i}}Recorder{{$tn}} {
+	return &{{$i}}Recorder{{$tn}}{impl: impl}
+}
+
+// Save writes the calls recorded so far to the named file. It fails if any
+// of the arguments or results could not be encoded.
+func (r *{{$i}}Recorder{{$tn}}) Save(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	p, err := {{$json}}.MarshalIndent(&{{$i}}CallLog{
+		Methods: {{$json}}.RawMessage({{$i}}CallSchema),
+		Calls:   r.calls,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return {{$os}}.WriteFile(name, append(p, '\n'), 0644)
+}
+
+// call gives a call of the method with its arguments encoded, before they are
+// possibly modified by the recorded implementation.
+func (r *{{$i}}Recorder{{$tn}}) call(method string, args ...any) *{{$i}}Call {
+	return &{{$i}}Call{Method: method, Args: r.encode(method, args)}
+}
+
+// record adds the call with its results to the log.
+func (r *{{$i}}Recorder{{$tn}}) record(c *{{$i}}Call, results ...any) {
+	c.Results = r.encode(c.Method, results)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, *c)
+}
+
+func (r *{{$i}}Recorder{{$tn}}) encode(method string, values []any) []{{$json}}.RawMessage {
+	var raw []{{$json}}.RawMessage
+	for _, v := range values {
+		p, err := {{$json}}.Marshal(v)
+		if err != nil {
+			r.mu.Lock()
+			if r.err == nil {
+				r.err = {{$fmt}}.Errorf("{{$i}}Recorder: %s: %w", method, err)
+			}
+			r.mu.Unlock()
+		}
+		raw = append(raw, p)
+	}
+	return raw
+}
+
+func (r *{{$i}}Recorder{{$tn}}) errMessage(err error) *string {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	return &msg
+}
+{{range $v.Interface.Methods}}{{$s := signature . "r" "c"}}{{if index $v.Unreplayable .Name}}
+// {{.Name}} calls {{.Name}} of the recorded implementation.
+func (r *{{$i}}Recorder{{$tn}}) {{$s}} {
+	{{if $s.Results}}return {{end}}r.impl.{{.Name}}({{$s.Args}})
+}
+{{else}}
+// {{.Name}} calls {{.Name}} of the recorded implementation and records the call.
+func (r *{{$i}}Recorder{{$tn}}) {{$s}} {
+	c := r.call("{{.Name}}"{{range $s.Params}}, {{.Name}}{{end}})
+	{{if $s.Results}}{{$s.ResultNames}} = {{end}}r.impl.{{.Name}}({{$s.Args}})
+	r.record(c{{range $s.Results}}, {{if eq .Type "error"}}r.errMessage({{.Name}}){{else}}{{.Name}}{{end}}{{end}})
+{{if $s.Results}}	return
+{{end}}}
+{{end}}{{end}}
+// {{$i}}Replayer is an implementation of {{$i}}, which replays calls from
+// a call log. A call is matched with the first recorded call of the same
+// method with equal arguments, which was not replayed yet; a call, which was
+// not recorded, panics.{{if $v.Skipped}}
+//
+// Methods which panic, since their results cannot be replayed: {{$v.Skipped}}.{{end}}
+type {{$i}}Replayer{{$tp}} struct {
+	mu    {{$v.Pkg "sync"}}.Mutex
+	calls []*{{$i}}Call
+}
+{{if not $tp}}
+var _ {{$i}} = (*{{$i}}Replayer)(nil)
+{{end}}
+// Open{{$i}}Replayer reads the named call log. It fails if the log was
+// recorded for methods different from the ones of {{$i}}.
+func Open{{$i}}Replayer{{$tp}}(name string) (*{{$i}}Replayer{{$tn}}, error) {
+	p, err := {{$os}}.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var l {{$i}}CallLog
+	if err := {{$json}}.Unmarshal(p, &l); err != nil {
+		return nil, err
+	}
+	var methods {{$bytes}}.Buffer
+	if err := {{$json}}.Compact(&methods, l.Methods); err != nil || methods.String() != {{$i}}CallSchema {
+		return nil, {{$v.Pkg "errors"}}.New("{{$i}}Replayer: " + name + " was not recorded for methods of {{$i}}")
+	}
+	r := new({{$i}}Replayer{{$tn}})
+	// Arguments are compared with ones of replayed calls in the compact form.
+	for i := range l.Calls {
+		c := &l.Calls[i]
+		for j, raw := range c.Args {
+			var buf {{$bytes}}.Buffer
+			if err := {{$json}}.Compact(&buf, raw); err != nil {
+				return nil, err
+			}
+			c.Args[j] = buf.Bytes()
+		}
+		r.calls = append(r.calls, c)
+	}
+	return r, nil
+}
+
+// Unreplayed gives the recorded calls, which were not replayed yet.
+func (r *{{$i}}Replayer{{$tn}}) Unreplayed() []{{$i}}Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []{{$i}}Call
+	for _, c := range r.calls {
+		if c != nil {
+			calls = append(calls, *c)
+		}
+	}
+	return calls
+}
+
+func (r *{{$i}}Replayer{{$tn}}) replay(method string, args ...any) *{{$i}}Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	raw := make([][]byte, len(args))
+	for i, v := range args {
+		p, err := {{$json}}.Marshal(v)
+		if err != nil {
+			panic({{$fmt}}.Sprintf("{{$i}}Replayer: %s: %s", method, err))
+		}
+		raw[i] = p
+	}
+calls:
+	for i, c := range r.calls {
+		if c == nil || c.Method != method || len(c.Args) != len(raw) {
+			continue
+		}
+		for j := range raw {
+			if !{{$bytes}}.Equal(c.Args[j], raw[j]) {
+				continue calls
+			}
+		}
+		r.calls[i] = nil
+		return c
+	}
+	panic({{$fmt}}.Sprintf("{{$i}}Replayer: call %s(%s) was not recorded", method, {{$bytes}}.Join(raw, []byte(", "))))
+}
+
+func (r *{{$i}}Replayer{{$tn}}) result(c *{{$i}}Call, i int, v any) {
+	if i >= len(c.Results) {
+		return
+	}
+	if err := {{$json}}.Unmarshal(c.Results[i], v); err != nil {
+		panic({{$fmt}}.Sprintf("{{$i}}Replayer: %s: %s", c.Method, err))
+	}
+}
+
+func (r *{{$i}}Replayer{{$tn}}) resultErr(c *{{$i}}Call, i int) error {
+	var msg *string
+	if r.result(c, i, &msg); msg == nil {
+		return nil
+	}
+	return {{$v.Pkg "errors"}}.New(*msg)
+}
+{{range $v.Interface.Methods}}{{$s := signature . "r" "c"}}{{if index $v.Unreplayable .Name}}
+// {{.Name}} panics, since calls of {{.Name}} cannot be replayed.
+func (r *{{$i}}Replayer{{$tn}}) {{$s}} {
+	panic("{{$i}}Replayer: calls of {{.Name}} cannot be replayed")
+}
+{{else}}
+// {{.Name}} replays a recorded call of {{.Name}}.
+func (r *{{$i}}Replayer{{$tn}}) {{$s}} {
+	{{if $s.Results}}c := {{end}}r.replay("{{.Name}}"{{range $s.Params}}, {{.Name}}{{end}})
+{{range $j, $p := $s.Results}}{{if eq $p.Type "error"}}	{{$p.Name}} = r.resultErr(c, {{$j}})
+{{else}}	r.result(c, {{$j}}, &{{$p.Name}})
+{{end}}{{end}}{{if $s.Results}}	return
+{{end}}}
+{{end}}{{end}}{{end}}`)
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rjeczalik/interfaces"
+)
+
+func init() {
+	register("retry", "Generate also a decorator of each interface, which retries failed calls "+
+		"of methods taking a context.Context first and returning an error last.", retryGen{})
+}
+
+type retryGen struct{}
+
+func (retryGen) deps() []string {
+	return []string{"context", "time"}
+}
+
+// retryMethods are methods and fields of a generated decorator, which must
+// not clash with methods of the interface.
+var retryMethods = []string{"attempt", "do", "impl", "policy"}
+
+// retryVars holds template variables of a retrying decorator.
+type retryVars struct {
+	*vars
+	Skipped string // comma-separated methods, which are not retried
+}
+
+func (retryGen) appendTemplate(v *vars, w io.Writer) error {
+	var skipped []string
+	if err := checkClash(v.Interface.Methods, retryMethods...); err != nil {
+		return fmt.Errorf("unable to generate retrying decorator for %s: %w", v.InterfaceName, err)
+	}
+	for _, fn := range v.Interface.Methods {
+		if !retryable(fn) {
+			skipped = append(skipped, fn.Name)
+		}
+	}
+	return retryTmpl.Execute(w, &retryVars{vars: v, Skipped: strings.Join(skipped, ", ")})
+}
+
+// retryable tells whether calls of the method can be retried, i.e. whether
+// it takes a context.Context first and returns an error last.
+func retryable(fn interfaces.Func) bool {
+	s := newSignature(fn)
+	return s.Context() != "" && s.Err() != ""
+}
+
+var retryTmpl = mustTemplate(`{{with $v := .}}{{$r := printf "%sRetrier" $v.InterfaceName}}{{$p := printf "%sRetryPolicy" $v.InterfaceName}}{{$tn := $v.Interface.TypeParams.Names}}{{$ctx := $v.Pkg "context"}}{{$time := $v.Pkg "time"}}
+// {{$p}} describes how calls of {{$r}} are retried.
+type {{$p}} struct {
+	MaxAttempts    int                                // maximum number of attempts of a call; 1 if not greater
+	Backoff        func(attempt int) {{$time}}.Duration // delay after the given failed attempt, counted from 1; none if nil
+	Retryable      func(err error) bool               // whether a failed attempt is retried; always if nil
+	Timeout        {{$time}}.Duration                 // timeout of a single attempt; none if 0
+	MethodTimeouts map[string]{{$time}}.Duration      // timeouts of single attempts by method, overriding Timeout
+}
+
+// {{$r}} is an implementation of {{$v.InterfaceName}}, which delegates calls
+// to another one, retrying failed ones according to a policy. Only methods
+// which take a context.Context first and return an error last are retried.
+// The context of a failed attempt is canceled when the attempt returns; one
+// of a successful attempt is not, as the results may be tied to it, and is
+// done only once the timeout of the attempt expires or the context of the
+// call is done. Until then it stays registered with the context of the call,
+// together with its timer.{{if $v.Skipped}}
+//
+// Methods passed through without retries: {{$v.Skipped}}.{{end}}
+type {{$r}}{{$v.Interface.TypeParams}} struct {
+	impl   {{$v.InterfaceName}}{{$tn}}
+	policy {{$p}}
+}
+{{if not $v.Interface.TypeParams}}
+var _ {{$v.InterfaceName}} = (*{{$r}})(nil)
+{{end}}
+// New{{$r}} gives a decorator of impl, which retries calls according to the policy.
+func New{{$r}}{{$v.Interface.TypeParams}}(impl {{$v.InterfaceName}}{{$tn}}, policy {{$p}}) *{{$r}}{{$tn}} {
+	return &{{$r}}{{$tn}}{impl: impl, policy: policy}
+}
+
+// do calls the method until it succeeds, the policy gives up or the context
+// is done. It gives the error of the last attempt.
+func (r *{{$r}}{{$tn}}) do(ctx {{$ctx}}.Context, method string, call func({{$ctx}}.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := r.attempt(ctx, method, call)
+		if err == nil || attempt >= r.policy.MaxAttempts || ctx.Err() != nil ||
+			r.policy.Retryable != nil && !r.policy.Retryable(err) {
+			return err
+		}
+		if r.policy.Backoff == nil {
+			continue
+		}
+		t := {{$time}}.NewTimer(r.policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// attempt calls the method once, with the timeout of the policy if any.
+func (r *{{$r}}{{$tn}}) attempt(ctx {{$ctx}}.Context, method string, call func({{$ctx}}.Context) error) error {
+	timeout, ok := r.policy.MethodTimeouts[method]
+	if !ok {
+		timeout = r.policy.Timeout
+	}
+	if timeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := {{$ctx}}.WithTimeout(ctx, timeout)
+	if err := call(ctx); err != nil {
+		cancel()
+		return err
+	}
+	// Results of a successful attempt, e.g. response bodies, may be tied to
+	// its context, so it is left to be canceled, releasing its timer, when
+	// its timeout expires or the parent context is done.
+	{{$ctx}}.AfterFunc(ctx, cancel)
+	return nil
+}
+{{range $v.Interface.Methods}}{{$s := signature . "r"}}{{if and $s.Context $s.Err}}
+// {{.Name}} calls {{.Name}} of the decorated implementation, retrying failed calls.
+func (r *{{$r}}{{$tn}}) {{$s}} {
+	{{$s.Err}} = r.do({{$s.Context}}, "{{.Name}}", func({{$s.Context}} {{$ctx}}.Context) error {
+		{{$s.ResultNames}} = r.impl.{{.Name}}({{$s.Args}})
+		return {{$s.Err}}
+	})
+	return
+}
+{{else}}
+// {{.Name}} calls {{.Name}} of the decorated implementation.
+func (r *{{$r}}{{$tn}}) {{$s}} {
+	{{if $s.Results}}return {{end}}r.impl.{{.Name}}({{$s.Args}})
+}
+{{end}}{{end}}{{end}}`)
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func init() {
+	register("slog", "Generate also a decorator of each interface, which logs calls with log/slog.", slogGen{})
+}
+
+type slogGen struct{}
+
+func (slogGen) deps() []string {
+	return []string{"context", "log/slog", "slices", "time"}
+}
+
+// slogMethods are methods and fields of a generated decorator, which must
+// not clash with methods of the interface.
+var slogMethods = []string{"Redact", "impl", "log", "logger", "redact"}
+
+func (slogGen) appendTemplate(v *vars, w io.Writer) error {
+	if err := checkClash(v.Interface.Methods, slogMethods...); err != nil {
+		return fmt.Errorf("unable to generate logging decorator for %s: %w", v.InterfaceName, err)
+	}
+	return slogTmpl.Execute(w, v)
+}
+
+var slogTmpl = mustTemplate(`{{with $v := .}}{{$l := printf "%sLogger" $v.InterfaceName}}{{$tn := $v.Interface.TypeParams.Names}}{{$slog := $v.Pkg "log/slog"}}{{$time := $v.Pkg "time"}}
+// {{$l}} is an implementation of {{$v.InterfaceName}}, which delegates calls
+// to another one and logs them with their arguments and durations. A leading
+// context argument is passed to the logger instead. Failed calls, ones which
+// last result is a non-nil error, are logged with the error at the error
+// level, others at the info level.
+type {{$l}}{{$v.Interface.TypeParams}} struct {
+	impl   {{$v.InterfaceName}}{{$tn}}
+	logger *{{$slog}}.Logger
+	redact map[string][]string
+}
+{{if not $v.Interface.TypeParams}}
+var _ {{$v.InterfaceName}} = (*{{$l}})(nil)
+{{end}}
+// New{{$l}} gives a decorator of impl, which logs calls with the logger.
+// If logger is nil, slog.Default() is used.
+func New{{$l}}{{$v.Interface.TypeParams}}(impl {{$v.InterfaceName}}{{$tn}}, logger *{{$slog}}.Logger) *{{$l}}{{$tn}} {
+	if logger == nil {
+		logger = {{$slog}}.Default()
+	}
+	return &{{$l}}{{$tn}}{impl: impl, logger: logger, redact: make(map[string][]string)}
+}
+
+// Redact makes the decorator log values of the given parameters of the method
+// as redacted; if no parameters are given, all of them are redacted. Parameters
+// are named as declared by {{$v.InterfaceName}}, unnamed ones as arg0, arg1 etc. It must
+// not be called concurrently with methods of {{$v.InterfaceName}}.
+func (l *{{$l}}{{$tn}}) Redact(method string, params ...string) *{{$l}}{{$tn}} {
+	l.redact[method] = append([]string{}, params...)
+	return l
+}
+
+// log logs a call of the method, which args are given as name and value pairs.
+func (l *{{$l}}{{$tn}}) log(ctx {{$v.Pkg "context"}}.Context, method string, start {{$time}}.Time, err error, args ...any) {
+	redact, ok := l.redact[method]
+	var params []{{$slog}}.Attr
+	for i := 0; i+1 < len(args); i += 2 {
+		name, value := args[i].(string), args[i+1]
+		if ok && (len(redact) == 0 || {{$v.Pkg "slices"}}.Contains(redact, name)) {
+			value = "[REDACTED]"
+		}
+		params = append(params, {{$slog}}.Any(name, value))
+	}
+	attrs := []{{$slog}}.Attr{
+		{{$slog}}.String("method", method),
+		{{$slog}}.Attr{Key: "args", Value: {{$slog}}.GroupValue(params...)},
+		{{$slog}}.Duration("duration", {{$time}}.Since(start)),
+	}
+	level := {{$slog}}.LevelInfo
+	if err != nil {
+		level = {{$slog}}.LevelError
+		attrs = append(attrs, {{$slog}}.Any("error", err))
+	}
+	l.logger.LogAttrs(ctx, level, "{{$v.InterfaceName}} call", attrs...)
+}
+{{range $v.Interface.Methods}}{{$s := signature . "l" "start"}}
+// {{.Name}} calls {{.Name}} of the decorated implementation and logs the call.
+func (l *{{$l}}{{$tn}}) {{$s}} {
+	start := {{$time}}.Now()
+	{{if $s.Results}}{{$s.ResultNames}} = {{end}}l.impl.{{.Name}}({{$s.Args}})
+	l.log({{or $s.Context (printf "%s.Background()" ($v.Pkg "context"))}}, "{{.Name}}", start, {{or $s.Err "nil"}}{{range $s.Params}}{{if ne .Name $s.Context}}, "{{.Key}}", {{.Name}}{{end}}{{end}})
+{{if $s.Results}}	return
+{{end}}}
+{{end}}{{end}}`)
@@ -0,0 +1,157 @@
+package interfaces
+
+import (
+	"go/ast"
+	"go/build"
+	"go/doc/comment"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// commentReader reads doc comments of methods. Methods declared in the
+// loaded package are looked up in its syntax trees, the ones promoted from
+// other packages, loaded from export data, are looked up in source files
+// parsed on demand.
+type commentReader struct {
+	fset  *token.FileSet         // file set of the loaded package
+	files map[string]*sourceFile // syntax trees by file name
+	other *token.FileSet         // file set of files parsed on demand
+}
+
+type sourceFile struct {
+	*ast.File
+	fset *token.FileSet
+}
+
+func newCommentReader(pkg *packages.Package) *commentReader {
+	r := &commentReader{
+		fset:  pkg.Fset,
+		files: make(map[string]*sourceFile),
+		other: token.NewFileSet(),
+	}
+	for _, f := range pkg.Syntax {
+		r.files[pkg.Fset.File(f.Pos()).Name()] = &sourceFile{File: f, fset: pkg.Fset}
+	}
+	return r
+}
+
+// doc gives doc comment text of the method or an empty string, if the method
+// is not documented or its sources are not available.
+func (r *commentReader) doc(method *types.Func) string {
+	pos := r.fset.Position(method.Origin().Pos())
+	if !pos.IsValid() {
+		return ""
+	}
+	f, ok := r.files[pos.Filename]
+	if !ok {
+		// The file set of the loaded package is not modified, as it is
+		// shared with the type checker.
+		filename := pos.Filename
+		if rel, ok := strings.CutPrefix(filename, "$GOROOT"); ok {
+			filename = filepath.Join(build.Default.GOROOT, filepath.FromSlash(rel))
+		}
+		if file, err := parser.ParseFile(r.other, filename, nil, parser.ParseComments); err == nil {
+			f = &sourceFile{File: file, fset: r.other}
+		}
+		r.files[pos.Filename] = f
+	}
+	if f == nil {
+		return ""
+	}
+	// Positions read from export data may lack columns and point at
+	// the beginning of a declaration, thus only lines are compared.
+	line := func(pos token.Pos) int {
+		return f.fset.Position(pos).Line
+	}
+	var doc *ast.CommentGroup
+	ast.Inspect(f.File, func(node ast.Node) bool {
+		if doc != nil {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			if line(node.Pos()) <= pos.Line && pos.Line <= line(node.Name.Pos()) {
+				doc = node.Doc
+			}
+			return false
+		case *ast.Field:
+			for _, name := range node.Names {
+				if line(name.Pos()) == pos.Line {
+					if doc = node.Doc; doc == nil {
+						doc = node.Comment
+					}
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return qualifyLinks(doc.Text(), method.Pkg())
+}
+
+// qualifyLinks rewrites doc links to symbols of the package, e.g. [File] or
+// [File.Read], to ones qualified with its import path, e.g. [os.File], as
+// the text is moved to a different package.
+func qualifyLinks(text string, pkg *types.Package) string {
+	if pkg == nil || !strings.Contains(text, "[") {
+		return text
+	}
+	p := comment.Parser{
+		LookupSym: func(recv, name string) bool {
+			if recv == "" {
+				return pkg.Scope().Lookup(name) != nil
+			}
+			obj, ok := pkg.Scope().Lookup(recv).(*types.TypeName)
+			if !ok {
+				return false
+			}
+			m, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg, name)
+			return m != nil
+		},
+	}
+	var links []string
+	var walk func([]comment.Text)
+	walk = func(text []comment.Text) {
+		for _, t := range text {
+			switch t := t.(type) {
+			case *comment.Link:
+				walk(t.Text)
+			case *comment.DocLink:
+				if t.ImportPath == "" {
+					name := t.Name
+					if t.Recv != "" {
+						name = t.Recv + "." + name
+					}
+					links = append(links, regexp.QuoteMeta(name))
+				}
+			}
+		}
+	}
+	for _, block := range p.Parse(text).Content {
+		switch block := block.(type) {
+		case *comment.Heading:
+			walk(block.Text)
+		case *comment.Paragraph:
+			walk(block.Text)
+		case *comment.List:
+			for _, item := range block.Items {
+				for _, block := range item.Content {
+					if par, ok := block.(*comment.Paragraph); ok {
+						walk(par.Text)
+					}
+				}
+			}
+		}
+	}
+	if len(links) == 0 {
+		return text
+	}
+	re := regexp.MustCompile(`(^|[^\w\])])\[(` + strings.Join(links, "|") + `)\]`)
+	return re.ReplaceAllString(text, "$1["+pkg.Path()+".$2]")
+}
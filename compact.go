@@ -0,0 +1,109 @@
+package interfaces
+
+import (
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// DefaultCompact is a list of packages, which interfaces are embedded in
+// place of matching methods when compacting an interface.
+var DefaultCompact = []string{"io", "fmt", "sort", "encoding"}
+
+// compactMethods replaces subsets of the methods with interfaces declared
+// in the opts.Compact packages, which consist of exactly the same methods.
+// The largest interfaces are tried first and each method is embedded at most
+// once, so the method set stays the same.
+//
+// The packages are looked up in the dependencies of pkg first, so types of
+// the method signatures are identical to the ones pkg uses. Other ones are
+// loaded from export data, as their interfaces may match only methods
+// made of predeclared types, like fmt.Stringer.
+func compactMethods(typ *types.Named, sels []*types.Selection, pkg *packages.Package,
+	opts *Options) ([]types.Type, []*types.Selection, error) {
+	var (
+		imported []*types.Package
+		missing  []string
+	)
+	for _, path := range opts.Compact {
+		if p := findPackage(pkg.Types, path, make(map[*types.Package]bool)); p != nil {
+			imported = append(imported, p)
+		} else {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) != 0 {
+		cfg := &packages.Config{
+			Mode:       packages.NeedName | packages.NeedTypes,
+			Dir:        opts.Dir,
+			Env:        opts.env(),
+			BuildFlags: opts.buildFlags(),
+		}
+		pkgs, err := packages.Load(cfg, missing...)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, path := range missing {
+			p := lookupPackage(pkgs, path)
+			if p == nil || len(p.Errors) != 0 {
+				return nil, nil, notLoadedErr(pkgs, path)
+			}
+			imported = append(imported, p.Types)
+		}
+	}
+	var candidates []*types.Named
+	for _, p := range imported {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !obj.Exported() || obj.IsAlias() || obj == typ.Origin().Obj() {
+				continue
+			}
+			if named, ok := obj.Type().(*types.Named); ok && compactable(named) {
+				candidates = append(candidates, named)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Underlying().(*types.Interface).NumMethods() >
+			candidates[j].Underlying().(*types.Interface).NumMethods()
+	})
+	byName := make(map[string]*types.Selection, len(sels))
+	for _, sel := range sels {
+		byName[sel.Obj().Name()] = sel
+	}
+	var embeds []types.Type
+	for _, named := range candidates {
+		iface := named.Underlying().(*types.Interface)
+		if !hasMethods(byName, iface) {
+			continue
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			delete(byName, iface.Method(i).Name())
+		}
+		embeds = append(embeds, named)
+	}
+	var rest []*types.Selection
+	for _, sel := range sels {
+		if byName[sel.Obj().Name()] != nil {
+			rest = append(rest, sel)
+		}
+	}
+	return embeds, rest, nil
+}
+
+// compactable tells whether the named type is a non-generic interface,
+// which consists of exported methods only.
+func compactable(named *types.Named) bool {
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok || named.TypeParams().Len() != 0 || !iface.IsMethodSet() || iface.NumMethods() == 0 {
+		return false
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		if !iface.Method(i).Exported() {
+			return false
+		}
+	}
+	return true
+}
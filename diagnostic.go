@@ -0,0 +1,78 @@
+package interfaces
+
+import (
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Diagnostic is an error reported while loading or type-checking a package.
+type Diagnostic struct {
+	Pos string `json:"pos,omitempty"` // position of the error, e.g. file:line:col, if known
+	Msg string `json:"msg"`           // error message
+}
+
+// String gives a text representation of the diagnostic, e.g.
+// "file.go:10:2: undefined: x".
+func (d Diagnostic) String() string {
+	if d.Pos == "" {
+		return d.Msg
+	}
+	return d.Pos + ": " + d.Msg
+}
+
+// diagnostics gives errors of the package. Compiler output reported by
+// the go command is skipped, if the package failed to type-check, as it
+// repeats the type-checking errors.
+func diagnostics(pkg *packages.Package) []Diagnostic {
+	typeErrors := false
+	for _, err := range pkg.Errors {
+		typeErrors = typeErrors || err.Kind == packages.TypeError
+	}
+	var diags []Diagnostic
+	for _, err := range pkg.Errors {
+		if typeErrors && err.Kind == packages.ListError && strings.HasPrefix(err.Msg, "# ") {
+			continue
+		}
+		d := Diagnostic{Pos: err.Pos, Msg: err.Msg}
+		if d.Pos == "-" {
+			d.Pos = ""
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// invalid tells whether the type failed to type-check.
+func (typ *Type) invalid() bool {
+	return typ.Kind == KindNamed && typ.ImportPath == "" && typ.Name == types.Typ[types.Invalid].Name()
+}
+
+// invalidEmbeds gives descriptions of types embedded by the named type, which
+// failed to type-check; methods they would promote are missing from the type.
+func invalidEmbeds(typ *types.Named) []string {
+	invalid := func(t types.Type) bool {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		return t == types.Typ[types.Invalid]
+	}
+	var embeds []string
+	switch u := typ.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); f.Embedded() && invalid(f.Type()) {
+				embeds = append(embeds, "embedded field "+f.Name())
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			if invalid(u.EmbeddedType(i)) {
+				embeds = append(embeds, "embedded interface "+strconv.Itoa(i+1))
+			}
+		}
+	}
+	return embeds
+}
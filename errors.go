@@ -0,0 +1,55 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+var (
+	// ErrTypeNotFound is returned when a queried type is declared neither in
+	// the queried package, nor in its external test package.
+	ErrTypeNotFound = errors.New("type not found")
+
+	// ErrNoMethods is returned when a queried type has no methods to build
+	// an interface from, e.g. all of them are unexported or filtered out.
+	ErrNoMethods = errors.New("no methods found")
+
+	// ErrInvalidType is returned in strict mode, when method signatures or
+	// embedded types of a queried type refer to types, which failed to
+	// type-check.
+	ErrInvalidType = errors.New("invalid type")
+)
+
+// ErrUnsupportedType is returned when a type, e.g. of a method parameter,
+// cannot be represented by a Type.
+type ErrUnsupportedType struct {
+	Type   types.Type // the offending type
+	Reason string     // why the type is not supported, if known
+}
+
+// Error implements the error interface.
+func (e *ErrUnsupportedType) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("unsupported type %s: %s", e.Type, e.Reason)
+	}
+	return fmt.Sprintf("unsupported type %s (%T)", e.Type, e.Type)
+}
+
+func typeNotFoundErr(opts *Options) error {
+	return fmt.Errorf("%w: %q (package %q)", ErrTypeNotFound, opts.Query.TypeName, opts.Query.Package)
+}
+
+func noMethodsErr(opts *Options) error {
+	return fmt.Errorf("%w for %q (package %q)", ErrNoMethods, opts.Query.TypeName, opts.Query.Package)
+}
+
+func invalidTypeErr(opts *Options, invalid []string, diags []Diagnostic) error {
+	err := fmt.Errorf("%w in %s of %q (package %q)", ErrInvalidType,
+		strings.Join(invalid, ", "), opts.Query.TypeName, opts.Query.Package)
+	if len(diags) != 0 {
+		err = fmt.Errorf("%w: %s", err, diags[0])
+	}
+	return err
+}
@@ -1,10 +1,16 @@
 package interfaces_test
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/rjeczalik/interfaces"
 )
@@ -35,6 +41,94 @@ func (ExampleBaz) D(*map[interface{}]struct{}, interface{}) (chan struct{}, []in
 
 func (*ExampleBaz) E(*[]map[*flag.FlagSet]struct{}, [3]string) {}
 
+type ExampleQux struct{}
+
+// F is a function with dependencies
+// on many packages, unlike [ExampleQux.G].
+func (ExampleQux) F(func(http.Handler) *template.Template, map[net.Flags]time.Time) chan<- *bytes.Buffer {
+	return nil
+}
+
+func (ExampleQux) G(struct{ URL *url.URL }, interface{ Close() error }) {
+}
+
+type ExampleReader struct {
+	*strings.Reader
+}
+
+type ExampleInner struct{}
+
+func (ExampleInner) Name() int {
+	return 0
+}
+
+type ExampleMiddle struct {
+	ExampleInner
+}
+
+type ExampleOther struct{}
+
+func (ExampleOther) Name() string {
+	return ""
+}
+
+func (ExampleOther) Len() int {
+	return 0
+}
+
+type ExampleSized struct{}
+
+func (ExampleSized) Len() int {
+	return 0
+}
+
+type ExampleOuter struct {
+	ExampleMiddle
+	ExampleOther
+	ExampleSized
+	io.Reader
+}
+
+type ExampleNode struct {
+	*ExampleNode
+	ExampleVisitor
+}
+
+type ExampleVisitor func(ExampleVisitor) ExampleVisitor
+
+func (*ExampleNode) Next() *ExampleNode {
+	return nil
+}
+
+func (ExampleVisitor) Visit(*ExampleNode) ExampleVisitor {
+	return nil
+}
+
+type ExampleStream interface {
+	io.ReadWriteCloser
+	fmt.Stringer
+	Flush() error
+}
+
+type ExampleLRU[K comparable, V any] struct{}
+
+func (*ExampleLRU[K, V]) Get(K) (V, bool) {
+	var v V
+	return v, false
+}
+
+func (*ExampleLRU[Key, Value]) Add(Key, Value) bool {
+	return false
+}
+
+func (*ExampleLRU[K, V]) Keys() []K {
+	return nil
+}
+
+func (*ExampleLRU[K, V]) Clone() *ExampleLRU[K, V] {
+	return nil
+}
+
 func ExampleNew() {
 	i, err := interfaces.New(`github.com/rjeczalik/interfaces.ExampleBaz`)
 	if err != nil {
@@ -42,7 +136,7 @@ func ExampleNew() {
 		return
 	}
 	fmt.Println("Interface:")
-	for _, fn := range i {
+	for _, fn := range i.Methods {
 		fmt.Println(fn)
 	}
 	fmt.Println("Dependencies:")
@@ -63,6 +157,238 @@ func ExampleNew() {
 	// net/http
 }
 
+func ExampleNew_generic() {
+	i, err := interfaces.New(`github.com/rjeczalik/interfaces.ExampleLRU`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Interface%s:\n", i.TypeParams)
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	// Output: Interface[K comparable, V any]:
+	// Add(K, V) bool
+	// Clone() *interfaces_test.ExampleLRU[K, V]
+	// Get(K) (V, bool)
+	// Keys() []K
+}
+
+func ExampleNew_instantiated() {
+	i, err := interfaces.New(`github.com/rjeczalik/interfaces.ExampleLRU[string,*net/http.Client]`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Interface:")
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	fmt.Println("Dependencies:")
+	for _, dep := range i.Deps() {
+		fmt.Println(dep)
+	}
+	// Output: Interface:
+	// Add(string, *http.Client) bool
+	// Clone() *interfaces_test.ExampleLRU[string, *http.Client]
+	// Get(string) (*http.Client, bool)
+	// Keys() []string
+	// Dependencies:
+	// github.com/rjeczalik/interfaces_test
+	// net/http
+}
+
+func ExampleNew_recursive() {
+	i, err := interfaces.New(`github.com/rjeczalik/interfaces.ExampleNode`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	// Output: Next() *interfaces_test.ExampleNode
+	// Visit(*interfaces_test.ExampleNode) interfaces_test.ExampleVisitor
+}
+
+func ExampleInterface_Deps() {
+	i, err := interfaces.New(`github.com/rjeczalik/interfaces.ExampleQux`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, dep := range i.Deps() {
+		fmt.Println(dep)
+	}
+	// Output: bytes
+	// html/template
+	// net
+	// net/http
+	// net/url
+	// time
+}
+
+func ExampleNewWithOptions_comments() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleQux",
+		},
+		Comments: true,
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, fn := range i.Methods {
+		fmt.Printf("%s: %q\n", fn.Name, fn.Doc)
+	}
+	opts.Query.TypeName = "ExampleReader"
+	if i, err = interfaces.NewWithOptions(opts); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, fn := range i.Methods[:2] {
+		fmt.Printf("%s: %q\n", fn.Name, fn.Doc)
+	}
+	// Output: F: "F is a function with dependencies\non many packages, unlike [github.com/rjeczalik/interfaces_test.ExampleQux.G].\n"
+	// G: ""
+	// Len: "Len returns the number of bytes of the unread portion of the\nstring.\n"
+	// Read: "Read implements the [io.Reader] interface.\n"
+}
+
+func ExampleNewWithOptions_embedded() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleOuter",
+		},
+		Warnf: func(format string, args ...interface{}) {
+			fmt.Printf("warning: "+format+"\n", args...)
+		},
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	// Output: warning: ambiguous selector ExampleOuter.Len
+	// Name() string
+	// Read([]byte) (int, error)
+}
+
+func ExampleNewWithOptions_receiver() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleBar",
+		},
+		Receiver: interfaces.ReceiverValue,
+		Warnf: func(format string, args ...interface{}) {
+			fmt.Printf("warning: "+format+"\n", args...)
+		},
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	// Output: warning: methods not in the method set of ExampleBar: B
+	// A(int) int
+	// C(map[string]int, *interfaces.Options, *http.Client) (chan []string, error)
+}
+
+func ExampleNewWithOptions_filter() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "os",
+			TypeName: "File",
+		},
+		Include: []string{"Read*", "/^Write(At)?$/"},
+		Exclude: []string{"ReadFrom", "Readdir*"},
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	opts.Exclude = append(opts.Exclude, "Readdirs")
+	if _, err := interfaces.NewWithOptions(opts); err != nil {
+		fmt.Println(err)
+	}
+	// Output: Read([]byte) (int, error)
+	// ReadAt([]byte, int64) (int, error)
+	// ReadDir(int) ([]os.DirEntry, error)
+	// Write([]byte) (int, error)
+	// WriteAt([]byte, int64) (int, error)
+	// pattern "Readdirs" does not match any method of "File" (package "os")
+}
+
+func ExampleNewWithOptions_embedded_interfaces() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleStream",
+		},
+		Exclude:      []string{"Write"},
+		KeepEmbedded: true,
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, typ := range i.Embeds {
+		fmt.Println(typ)
+	}
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	// Output: fmt.Stringer
+	// io.Closer
+	// io.Reader
+	// Flush() error
+}
+
+func ExampleNewWithOptions_compact() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleReader",
+		},
+		Compact: interfaces.DefaultCompact,
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, typ := range i.Embeds {
+		fmt.Println(typ)
+	}
+	for _, fn := range i.Methods {
+		fmt.Println(fn)
+	}
+	// Output: io.ByteScanner
+	// io.ReadSeeker
+	// io.ReaderAt
+	// io.RuneScanner
+	// io.WriterTo
+	// Len() int
+	// Reset(string)
+	// Size() int64
+}
+
 func ExampleNewWithOptions() {
 	opts := &interfaces.Options{
 		Query: &interfaces.Query{
@@ -76,7 +402,7 @@ func ExampleNewWithOptions() {
 		return
 	}
 	fmt.Println("Interface:")
-	for _, fn := range i {
+	for _, fn := range i.Methods {
 		fmt.Println(fn)
 	}
 	fmt.Println("Dependencies:")
@@ -90,6 +416,76 @@ func ExampleNewWithOptions() {
 	// net
 }
 
+func ExampleNewBatch() {
+	opts := []*interfaces.Options{{
+		Query: &interfaces.Query{
+			Package:  "io",
+			TypeName: "ReadCloser",
+		},
+	}, {
+		Query: &interfaces.Query{
+			Package:  "net/http",
+			TypeName: "RoundTripper",
+		},
+	}}
+	inters, err := interfaces.NewBatch(opts...)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, i := range inters {
+		fmt.Println(i.Methods)
+	}
+	// Output: [Close() error Read([]byte) (int, error)]
+	// [RoundTrip(*http.Request) (*http.Response, error)]
+}
+
+func ExampleLoader() {
+	l := interfaces.NewLoader(nil)
+	i, err := l.Interface(&interfaces.Query{Package: "net/http", TypeName: "Handler"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(i.Methods)
+	s, err := l.Struct(&interfaces.Query{Package: "net/http", TypeName: "Cookie"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, f := range s[:3] {
+		fmt.Println(f.Name, f.Type)
+	}
+	// Output: [ServeHTTP(http.ResponseWriter, *http.Request)]
+	// Name string
+	// Value string
+	// Quoted bool
+}
+
+func ExampleNewResult() {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleBaz",
+		},
+	}
+	res, err := interfaces.NewResult(opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(res.ImportPath, res.TypeName, res.Kind)
+	for _, m := range res.Methods {
+		fmt.Printf("%s: %s receiver of %s, %s\n", m.Name, m.Receiver, m.Recv, m.EmbeddingPath(res.TypeName))
+	}
+	// Output: github.com/rjeczalik/interfaces_test ExampleBaz struct
+	// A: value receiver of ExampleBar, ExampleBaz -> *ExampleBar
+	// B: pointer receiver of *ExampleBar, ExampleBaz -> *ExampleBar
+	// C: value receiver of ExampleBar, ExampleBaz -> *ExampleBar
+	// D: value receiver of ExampleBaz, ExampleBaz
+	// E: pointer receiver of *ExampleBaz, ExampleBaz
+}
+
 func ExampleFunc_String() {
 	f := interfaces.Func{
 		Name: "Close",
@@ -98,3 +494,17 @@ func ExampleFunc_String() {
 	fmt.Println(f)
 	// Output: Close() error
 }
+
+func ExampleFunc_NamedString() {
+	f := interfaces.Func{
+		Name:     "Copy",
+		Ins:      []interfaces.Type{{Name: "string"}, {Name: "string"}, {Name: "bool"}},
+		Outs:     []interfaces.Type{{Name: "int64"}, {Name: "error"}},
+		InNames:  []string{"dst", "src", ""},
+		OutNames: []string{"n", "err"},
+	}
+	fmt.Println(f)
+	fmt.Println(f.NamedString())
+	// Output: Copy(string, string, bool) (int64, error)
+	// Copy(dst string, src string, _ bool) (n int64, err error)
+}
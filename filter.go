@@ -0,0 +1,101 @@
+package interfaces
+
+import (
+	"fmt"
+	"go/types"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// pattern is a method name pattern; either a glob, as accepted by path.Match,
+// e.g. Get*Input, or a regular expression enclosed in slashes,
+// e.g. /^(Get|List).*Input$/.
+type pattern struct {
+	raw     string
+	match   func(string) bool
+	matched bool
+}
+
+func newPattern(raw string) (*pattern, error) {
+	p := &pattern{raw: raw}
+	if len(raw) > 1 && strings.HasPrefix(raw, "/") && strings.HasSuffix(raw, "/") {
+		re, err := regexp.Compile(raw[1 : len(raw)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", raw, err)
+		}
+		p.match = re.MatchString
+		return p, nil
+	}
+	if _, err := path.Match(raw, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %s", raw, err)
+	}
+	p.match = func(name string) bool {
+		ok, _ := path.Match(raw, name)
+		return ok
+	}
+	return p, nil
+}
+
+func newPatterns(raw []string) ([]*pattern, error) {
+	patterns := make([]*pattern, len(raw))
+	for i, raw := range raw {
+		p, err := newPattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = p
+	}
+	return patterns, nil
+}
+
+// matchAny tells whether the name matches any of the patterns; all of them
+// are tried, so each matching one is marked as matched.
+func matchAny(patterns []*pattern, name string) bool {
+	ok := false
+	for _, p := range patterns {
+		if p.match(name) {
+			p.matched = true
+			ok = true
+		}
+	}
+	return ok
+}
+
+// filterMethods filters methods by their names with opts.Include and
+// opts.Exclude patterns. A method is kept, if it matches any include
+// pattern, or there are none, and does not match any exclude pattern.
+//
+// It fails if any of the patterns does not match any of the methods, to
+// prevent typos from silently producing smaller interfaces, unless
+// opts.Unmatched is set.
+func filterMethods(sels []*types.Selection, opts *Options) ([]*types.Selection, error) {
+	if len(opts.Include) == 0 && len(opts.Exclude) == 0 {
+		return sels, nil
+	}
+	include, err := newPatterns(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := newPatterns(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []*types.Selection
+	for _, sel := range sels {
+		name := sel.Obj().Name()
+		included := matchAny(include, name) || len(include) == 0
+		if excluded := matchAny(exclude, name); included && !excluded {
+			filtered = append(filtered, sel)
+		}
+	}
+	for _, p := range append(include, exclude...) {
+		if !p.matched && opts.Unmatched != nil {
+			opts.Unmatched(p.raw)
+		} else if !p.matched {
+			return nil, fmt.Errorf("pattern %q does not match any method of %q (package %q)",
+				p.raw, opts.Query.TypeName, opts.Query.Package)
+		}
+	}
+	return filtered, nil
+}
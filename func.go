@@ -3,79 +3,186 @@ package interfaces
 import (
 	"bytes"
 	"fmt"
-	"sort"
-	"strings"
+	"go/token"
+	"go/types"
+	"strconv"
 )
 
 // Func represents an interface function.
 type Func struct {
-	Name       string `json:"name,omitempty"` // name of the function
-	Ins        []Type `json:"ins,omitempty"`  // input parameters
-	Outs       []Type `json:"outs,omitempty"` // output parameters
-	IsVariadic bool   // whether the function is variadic
-}
+	Name       string   `json:"name,omitempty"`     // name of the function
+	Ins        []Type   `json:"ins,omitempty"`      // input parameters
+	Outs       []Type   `json:"outs,omitempty"`     // output parameters
+	InNames    []string `json:"inNames,omitempty"`  // names of input parameters, if any; empty for blank ones
+	OutNames   []string `json:"outNames,omitempty"` // names of output parameters, if any; empty for blank ones
+	IsVariadic bool     // whether the function is variadic
 
-var variadic = strings.NewReplacer("[]", "...")
+	Doc string         `json:"doc,omitempty"` // doc comment text, if read, with doc links qualified
+	Pos token.Position `json:"pos,omitzero"`  // position of the declaration in source
+}
 
 // String gives Go code representation of the function.
 func (f Func) String() string {
+	return f.string(false)
+}
+
+// NamedString gives Go code representation of the function, which includes
+// names of the parameters. Blank names are written as _, unless all
+// the parameters of a list are blank, in which case names are left out.
+func (f Func) NamedString() string {
+	return f.string(true)
+}
+
+func (f Func) string(names bool) string {
 	var buf bytes.Buffer
+	ins := f.names(f.InNames, len(f.Ins), names)
+	outs := f.names(f.OutNames, len(f.Outs), names)
 	if len(f.Ins) == 0 {
 		fmt.Fprintf(&buf, "%s()", f.Name)
 	} else {
-		fmt.Fprintf(&buf, "%s(%s", f.Name, f.in(0))
+		fmt.Fprintf(&buf, "%s(%s%s", f.Name, ins[0], f.in(0))
 		for i := range f.Ins[1:] {
-			fmt.Fprintf(&buf, ", %s", f.in(i+1))
+			fmt.Fprintf(&buf, ", %s%s", ins[i+1], f.in(i+1))
 		}
 		buf.WriteString(")")
 	}
-	if len(f.Outs) == 1 {
+	if len(f.Outs) == 1 && outs[0] == "" {
 		fmt.Fprintf(&buf, " %s", f.Outs[0])
-	} else if len(f.Outs) > 1 {
-		fmt.Fprintf(&buf, " (%s", f.Outs[0])
-		for _, typ := range f.Outs[1:] {
-			fmt.Fprintf(&buf, ", %s", typ)
+	} else if len(f.Outs) != 0 {
+		fmt.Fprintf(&buf, " (%s%s", outs[0], f.Outs[0])
+		for i, typ := range f.Outs[1:] {
+			fmt.Fprintf(&buf, ", %s%s", outs[i+1], typ)
 		}
 		buf.WriteString(")")
 	}
 	return buf.String()
 }
 
+// names gives name prefixes for n parameters, e.g. "src ".
+func (f Func) names(names []string, n int, ok bool) []string {
+	prefixes := make([]string, n)
+	if !ok || len(names) != n {
+		return prefixes
+	}
+	for i, name := range names {
+		if name == "" {
+			name = "_"
+		}
+		prefixes[i] = name + " "
+	}
+	return prefixes
+}
+
 func (f Func) in(i int) string {
-	if typ := f.Ins[i]; i == len(f.Ins)-1 && f.IsVariadic {
-		return variadic.Replace(typ.String())
+	if typ := f.Ins[i]; i == len(f.Ins)-1 && f.IsVariadic && typ.Kind == KindSlice {
+		return "..." + typ.Elem.String()
 	} else {
 		return typ.String()
 	}
 }
 
+func newFunc(name string, sig *types.Signature, seen map[types.Type]bool) (Func, error) {
+	ins := sig.Params()
+	outs := sig.Results()
+	fn := Func{
+		Name:       name,
+		Ins:        make([]Type, ins.Len()),
+		Outs:       make([]Type, outs.Len()),
+		IsVariadic: sig.Variadic(),
+	}
+	for i := range fn.Ins {
+		if err := fn.Ins[i].setFromType(ins.At(i).Type(), seen); err != nil {
+			return Func{}, err
+		}
+	}
+	for i := range fn.Outs {
+		if err := fn.Outs[i].setFromType(outs.At(i).Type(), seen); err != nil {
+			return Func{}, err
+		}
+	}
+	fn.InNames = paramNames(ins)
+	fn.OutNames = paramNames(outs)
+	return fn, nil
+}
+
+// paramNames gives names of the parameters, or nil if all of them
+// are blank or missing.
+func paramNames(params *types.Tuple) []string {
+	var names []string
+	for i := 0; i < params.Len(); i++ {
+		if name := params.At(i).Name(); name != "" && name != "_" {
+			if names == nil {
+				names = make([]string, params.Len())
+			}
+			names[i] = name
+		}
+	}
+	return names
+}
+
+// renameParams renames parameters which would shadow packages, either ones
+// referred to by the signature or the given ones, e.g. used by generated code.
+func (f *Func) renameParams(pkgs map[string]bool) {
+	used := make(map[string]bool, len(pkgs))
+	for name := range pkgs {
+		used[name] = true
+	}
+	f.walk(func(typ *Type) {
+		if typ.Package != "" {
+			used[typ.Package] = true
+		}
+	})
+	taken := make(map[string]bool)
+	for _, names := range [][]string{f.InNames, f.OutNames} {
+		for _, name := range names {
+			taken[name] = true
+		}
+	}
+	for _, names := range [][]string{f.InNames, f.OutNames} {
+		for i, name := range names {
+			if name == "" || !used[name] {
+				continue
+			}
+			for n := 2; ; n++ {
+				if candidate := name + strconv.Itoa(n); !used[candidate] && !taken[candidate] {
+					names[i] = candidate
+					taken[candidate] = true
+					break
+				}
+			}
+		}
+	}
+}
+
 // Deps gives a list of packages the function depends on. E.g. if the function
 // represents Serve(net.Listener, http.Handler) error, calling Deps() will
-// return []string{"http", "net"}.
+// return []string{"net", "net/http"}.
+//
+// Packages of all the types the signature is composed of are included, e.g.
+// ones of func parameters, map keys or fields of struct literals.
 //
-// The packages are sorted by name.
+// The packages are sorted by import path.
 func (f Func) Deps() []string {
-	pkgs := make(map[string]struct{}, 0)
-	for _, in := range f.Ins {
-		pkgs[in.ImportPath] = struct{}{}
+	pkgs := make(map[string]struct{})
+	f.importPaths(pkgs)
+	return sortedDeps(pkgs)
+}
+
+func (f *Func) importPaths(pkgs map[string]struct{}) {
+	for i := range f.Ins {
+		f.Ins[i].importPaths(pkgs)
 	}
-	for _, out := range f.Outs {
-		pkgs[out.ImportPath] = struct{}{}
+	for i := range f.Outs {
+		f.Outs[i].importPaths(pkgs)
 	}
-	delete(pkgs, "")
-	if len(pkgs) == 0 {
-		return nil
+}
+
+// walk calls fn for all the types the function signature is composed of.
+func (f *Func) walk(fn func(*Type)) {
+	for i := range f.Ins {
+		f.Ins[i].walk(fn)
 	}
-	deps := make([]string, 0, len(pkgs))
-	for pkg := range pkgs {
-		deps = append(deps, pkg)
+	for i := range f.Outs {
+		f.Outs[i].walk(fn)
 	}
-	sort.Strings(deps)
-	return deps
 }
-
-type funcs []Func
-
-func (f funcs) Len() int           { return len(f) }
-func (f funcs) Less(i, j int) bool { return f[i].Name < f[j].Name }
-func (f funcs) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
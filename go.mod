@@ -1,5 +1,10 @@
 module github.com/rjeczalik/interfaces
 
-go 1.18
+go 1.26.0
 
-require golang.org/x/tools v0.0.0-20190501045030-23463209683d
+require golang.org/x/tools v0.50.0
+
+require (
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+)
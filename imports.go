@@ -0,0 +1,192 @@
+package interfaces
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Import represents a single import spec of a generated file.
+type Import struct {
+	Name string `json:"name,omitempty"` // explicit package name; empty if not needed
+	Path string `json:"path,omitempty"` // import path
+}
+
+// String gives Go code representation of the import spec.
+func (imp Import) String() string {
+	if imp.Name != "" {
+		return imp.Name + " " + strconv.Quote(imp.Path)
+	}
+	return strconv.Quote(imp.Path)
+}
+
+// ImportSet resolves unique names for packages imported by a generated file.
+type ImportSet struct {
+	reserved map[string]bool
+	names    map[string]string // import path to package name
+	fixed    []string          // paths added with Add
+	types    []*Type
+	funcs    []*Func
+	resolved map[string]string // import path to resolved name
+}
+
+// NewImportSet gives new import set. The reserved names are never used
+// as package names, e.g. the name of the generated package.
+func NewImportSet(reserved ...string) *ImportSet {
+	s := &ImportSet{
+		reserved: make(map[string]bool),
+		names:    make(map[string]string),
+	}
+	for _, name := range reserved {
+		s.reserved[name] = true
+	}
+	return s
+}
+
+// Add adds a package with the given import path and name to the set,
+// e.g. one used by generated code itself. Names of packages added with
+// Add are resolved before the ones of interfaces.
+func (s *ImportSet) Add(path, name string) {
+	if _, ok := s.names[path]; !ok {
+		s.fixed = append(s.fixed, path)
+	}
+	s.names[path] = name
+}
+
+// AddInterface adds all the packages the interface depends on to the set.
+// Names of its type parameters are reserved. Qualifiers of the types of
+// the interface are rewritten to the resolved names by Resolve, and so are
+// parameter names of its methods which would shadow packages referred to
+// by their signatures or added with Add.
+func (s *ImportSet) AddInterface(i *Interface) {
+	for _, tp := range i.TypeParams {
+		s.reserved[tp.Name] = true
+	}
+	for j := range i.Methods {
+		s.funcs = append(s.funcs, &i.Methods[j])
+	}
+	i.walk(func(typ *Type) {
+		if typ.ImportPath == "" {
+			return
+		}
+		if _, ok := s.names[typ.ImportPath]; !ok {
+			s.names[typ.ImportPath] = typ.Package
+		}
+		s.types = append(s.types, typ)
+	})
+}
+
+// Resolve assigns unique names to the packages of the set and rewrites
+// qualifiers of types of the added interfaces to match them.
+//
+// Packages are given their own names, unless they collide with a reserved
+// name or with a name of another package. A colliding package is given
+// a name prefixed with parent elements of its import path, e.g. mathrand
+// for math/rand, or suffixed with a number if that fails. As the paths are
+// processed in a sorted order, the names are stable.
+//
+// It gives the imports sorted by import path; an explicit name is set only
+// for those which name can't be inferred from the import path.
+func (s *ImportSet) Resolve() []Import {
+	paths := make([]string, 0, len(s.names))
+	for path := range s.names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	sort.Strings(s.fixed)
+	used := make(map[string]bool, len(s.reserved)+len(paths))
+	for name := range s.reserved {
+		used[name] = true
+	}
+	s.resolved = make(map[string]string, len(paths))
+	for _, list := range [][]string{s.fixed, paths} {
+		for _, path := range list {
+			if _, ok := s.resolved[path]; ok {
+				continue
+			}
+			name := uniqueName(path, s.names[path], used)
+			used[name] = true
+			s.resolved[path] = name
+		}
+	}
+	for _, typ := range s.types {
+		typ.Package = s.resolved[typ.ImportPath]
+	}
+	fixed := make(map[string]bool, len(s.fixed))
+	for _, path := range s.fixed {
+		fixed[s.resolved[path]] = true
+	}
+	for _, fn := range s.funcs {
+		fn.renameParams(fixed)
+	}
+	imports := make([]Import, len(paths))
+	for i, path := range paths {
+		imports[i].Path = path
+		if name := s.resolved[path]; name != assumedName(path) {
+			imports[i].Name = name
+		}
+	}
+	return imports
+}
+
+// Name gives the name resolved for a package with the given import path.
+// It gives an empty string if the path was not added to the set or Resolve
+// was not called yet.
+func (s *ImportSet) Name(path string) string {
+	return s.resolved[path]
+}
+
+// uniqueName gives a name for a package, which is not used yet.
+func uniqueName(path, name string, used map[string]bool) string {
+	if !used[name] {
+		return name
+	}
+	elems := strings.Split(path, "/")
+	candidate := name
+	for i := len(elems) - 2; i >= 0; i-- {
+		candidate = identifier(elems[i]) + candidate
+		if !used[candidate] {
+			return candidate
+		}
+	}
+	for n := 2; ; n++ {
+		if candidate := name + strconv.Itoa(n); !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+// identifier gives s with all characters which are not valid in
+// a package name removed.
+func identifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(s))
+}
+
+// assumedName gives a package name that is conventionally assumed
+// for the import path, the same way goimports does, e.g. yaml for
+// gopkg.in/yaml.v3 or errors for github.com/pkg/errors/v2.
+func assumedName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	n, err := strconv.Atoi(s[1:])
+	return err == nil && n > 1 && s[1] != '0'
+}
@@ -0,0 +1,95 @@
+package interfaces_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rjeczalik/interfaces"
+)
+
+func TestImportSet(t *testing.T) {
+	named := func(pkg, path, name string) interfaces.Type {
+		return interfaces.Type{
+			Name:       name,
+			Package:    pkg,
+			ImportPath: path,
+		}
+	}
+	i := interfaces.Interface{
+		TypeParams: interfaces.TypeParams{
+			{Name: "mathrand", Constraint: interfaces.Type{Name: "any"}},
+		},
+		Methods: []interfaces.Func{{
+			Name: "Rand",
+			Ins: []interfaces.Type{
+				named("rand", "math/rand", "Rand"),
+				named("rand", "crypto/rand", "Source"),
+			},
+			InNames: []string{"rand", "rand2"},
+			Outs: []interfaces.Type{
+				named("yaml", "gopkg.in/yaml.v3", "Node"),
+				named("bar", "example.com/go-foo", "Bar"),
+				named("mock", "example.com/mock", "T"),
+			},
+		}},
+	}
+	imports := interfaces.NewImportSet("mock")
+	imports.Add("context", "context")
+	imports.AddInterface(&i)
+	want := []interfaces.Import{
+		{Path: "context"},
+		{Path: "crypto/rand"},
+		{Name: "bar", Path: "example.com/go-foo"},
+		{Name: "examplecommock", Path: "example.com/mock"},
+		{Path: "gopkg.in/yaml.v3"},
+		{Name: "rand2", Path: "math/rand"},
+	}
+	if got := imports.Resolve(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+	wantFn := "Rand(rand2.Rand, rand.Source) (yaml.Node, bar.Bar, examplecommock.T)"
+	if got := i.Methods[0].String(); got != wantFn {
+		t.Errorf("got %q; want %q", got, wantFn)
+	}
+	wantFn = "Rand(rand3 rand2.Rand, rand22 rand.Source) (yaml.Node, bar.Bar, examplecommock.T)"
+	if got := i.Methods[0].NamedString(); got != wantFn {
+		t.Errorf("got %q; want %q", got, wantFn)
+	}
+	if got := imports.Name("context"); got != "context" {
+		t.Errorf("got %q; want %q", got, "context")
+	}
+}
+
+func TestImportSetParamNames(t *testing.T) {
+	basic := interfaces.Type{Name: "int64"}
+	source := interfaces.Type{Name: "Source", Package: "rand", ImportPath: "math/rand"}
+	i := interfaces.Interface{
+		Methods: []interfaces.Func{{
+			Name:    "Seed",
+			Ins:     []interfaces.Type{basic},
+			InNames: []string{"mathrand"},
+		}, {
+			Name:    "Int63n",
+			Ins:     []interfaces.Type{basic, basic},
+			InNames: []string{"rand", "fmt"},
+		}, {
+			Name:     "Source",
+			Outs:     []interfaces.Type{source},
+			OutNames: []string{"mathrand"},
+		}},
+	}
+	imports := interfaces.NewImportSet("rand", "Rand")
+	imports.Add("fmt", "fmt")
+	imports.AddInterface(&i)
+	imports.Resolve()
+	want := []string{
+		"Seed(mathrand int64)",
+		"Int63n(rand int64, fmt2 int64)",
+		"Source() (mathrand2 mathrand.Source)",
+	}
+	for j, fn := range i.Methods {
+		if got := fn.NamedString(); got != want[j] {
+			t.Errorf("%d: got %q; want %q", j, got, want[j])
+		}
+	}
+}
@@ -4,25 +4,32 @@ import (
 	"errors"
 	"fmt"
 	"go/types"
+	"reflect"
 	"sort"
-	"unicode"
 
-	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/packages"
 )
 
 // Interface represents a typed interface.
-type Interface []Func
+type Interface struct {
+	TypeParams TypeParams `json:"typeParams,omitempty"` // type parameters of a generic interface
+	Embeds     []Type     `json:"embeds,omitempty"`     // embedded interfaces, if kept
+	Methods    []Func     `json:"methods,omitempty"`    // interface methods
+
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"` // errors of loading or type-checking the package of the type
+}
 
 // New builds an interface definition for a type specified by the query.
 // Supported query format is "package".Type (similar to what gorename
 // tool accepts).
 //
-// The function expects sources for the requested type to be present
-// in current GOPATH.
+// The function expects sources for the requested type to be resolvable
+// from the current working directory, either within the main module
+// (or workspace) or in GOPATH.
 func New(query string) (Interface, error) {
 	q, err := ParseQuery(query)
 	if err != nil {
-		return nil, errors.New("invalid query: " + err.Error())
+		return Interface{}, fmt.Errorf("invalid query: %w", err)
 	}
 	opts := &Options{
 		Query: q,
@@ -33,14 +40,15 @@ func New(query string) (Interface, error) {
 // NewWithOptions builds an interface definition for a type specified by
 // the given Options.
 //
-// The Options may be used to specify e.g. different GOPATH if sources
-// for requested type are not available in the current one.
+// The Options may be used to specify e.g. different working directory or
+// build flags if sources for requested type are not available in the
+// current module.
 func NewWithOptions(opts *Options) (Interface, error) {
-	if opts == nil || opts.Query == nil {
-		panic("interfacer: called NewWithOptions with nil Options or nil Query")
+	if opts == nil {
+		return Interface{}, errors.New("interfaces: called NewWithOptions with nil Options")
 	}
 	if err := opts.Query.valid(); err != nil {
-		return nil, errors.New("invalid query: " + err.Error())
+		return Interface{}, fmt.Errorf("invalid query: %w", err)
 	}
 	return buildInterface(opts)
 }
@@ -48,42 +56,93 @@ func NewWithOptions(opts *Options) (Interface, error) {
 // Deps gives a list of packages the interface depends on.
 func (i Interface) Deps() []string {
 	pkgs := make(map[string]struct{})
-	for _, fn := range i {
-		for _, pkg := range fn.Deps() {
-			pkgs[pkg] = struct{}{}
+	for _, tp := range i.TypeParams {
+		tp.Constraint.importPaths(pkgs)
+	}
+	for _, typ := range i.Embeds {
+		typ.importPaths(pkgs)
+	}
+	for _, fn := range i.Methods {
+		fn.importPaths(pkgs)
+	}
+	return sortedDeps(pkgs)
+}
+
+// walk calls fn for all the types the interface is composed of.
+func (i *Interface) walk(fn func(*Type)) {
+	for j := range i.TypeParams {
+		i.TypeParams[j].Constraint.walk(fn)
+	}
+	for j := range i.Embeds {
+		i.Embeds[j].walk(fn)
+	}
+	for j := range i.Methods {
+		i.Methods[j].walk(fn)
+	}
+}
+
+// NewBatch builds interface definitions for types specified by each of
+// the given Options, loading and type-checking the packages only once.
+//
+// Settings of the go command, i.e. Context, Dir, BuildFlags and Env, must be
+// the same for all the Options. Interfaces are returned in the same order.
+func NewBatch(opts ...*Options) ([]Interface, error) {
+	var patterns []string
+	for i, o := range opts {
+		if o == nil {
+			return nil, fmt.Errorf("interfaces: called NewBatch with nil Options %d", i)
 		}
+		if err := o.Query.valid(); err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		if o.Dir != opts[0].Dir || !reflect.DeepEqual(o.env(), opts[0].env()) ||
+			!reflect.DeepEqual(o.buildFlags(), opts[0].buildFlags()) {
+			return nil, fmt.Errorf("go command settings of options %d differ from the first ones", i)
+		}
+		patterns = append(patterns, o.Query.Packages()...)
 	}
-	if len(pkgs) == 0 {
-		return nil
+	if len(opts) == 0 {
+		return nil, nil
 	}
-	deps := make([]string, 0, len(pkgs))
-	for pkg := range pkgs {
-		deps = append(deps, pkg)
+	pkgs, err := load(opts[0], patterns...)
+	if err != nil {
+		return nil, err
 	}
-	sort.Strings(deps)
-	return deps
+	inters := make([]Interface, len(opts))
+	for i, o := range opts {
+		res, err := buildResultFrom(pkgs, o)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", o.Query.Package, o.Query.TypeName, err)
+		}
+		inters[i] = res.Interface
+	}
+	return inters, nil
 }
 
 func buildInterface(opts *Options) (Interface, error) {
-	cfg := &loader.Config{
-		AllowErrors:         true,
-		Build:               opts.context(),
-		ImportPkgs:          map[string]bool{opts.Query.Package: true},
-		TypeCheckFuncBodies: func(string) bool { return false },
-	}
-	cfg.ImportWithTests(opts.Query.Package)
-	prog, err := cfg.Load()
+	res, err := buildResult(opts)
+	if err != nil {
+		return Interface{}, err
+	}
+	return res.Interface, nil
+}
+
+func buildResult(opts *Options) (*Result, error) {
+	pkgs, err := load(opts, opts.Query.Packages()...)
 	if err != nil {
 		return nil, err
 	}
-	pkg, ok := prog.Imported[opts.Query.Package]
-	if !ok {
-		return nil, fmt.Errorf("parsing successful, but package %q not found",
-			opts.Query.Package)
+	return buildResultFrom(pkgs, opts)
+}
+
+func buildResultFrom(pkgs []*packages.Package, opts *Options) (*Result, error) {
+	pkg := lookupPackage(pkgs, opts.Query.Package)
+	if pkg == nil {
+		return nil, notLoadedErr(pkgs, opts.Query.Package)
 	}
-	i, err := buildInterfaceForPkg(pkg, opts)
-	if err == nil {
-		return i, nil
+	res, err := buildResultForPkg(pkg, pkgs, opts)
+	if !errors.Is(err, ErrTypeNotFound) {
+		return res, err
 	}
 	// If a requested type is defined in an external test package try to
 	// build the interface using it before returning an error.
@@ -91,101 +150,185 @@ func buildInterface(opts *Options) (Interface, error) {
 	queryCopy.Package += "_test"
 	optsCopy := *opts
 	optsCopy.Query = &queryCopy
-	for _, pkg := range prog.Created {
-		if pkg.Pkg.Path() == optsCopy.Query.Package {
-			return buildInterfaceForPkg(pkg, &optsCopy)
+	if pkg := lookupPackage(pkgs, optsCopy.Query.Package); pkg != nil {
+		if res, testErr := buildResultForPkg(pkg, pkgs, &optsCopy); !errors.Is(testErr, ErrTypeNotFound) {
+			return res, testErr
 		}
 	}
 	return nil, err
 }
 
-func buildInterfaceForPkg(pkg *loader.PackageInfo, opts *Options) (Interface, error) {
-	var typ *types.Named
-	for _, obj := range pkg.Defs {
-		if obj == nil {
-			continue
-		}
-		if obj.Name() != opts.Query.TypeName || obj.Pkg().Path() != opts.Query.Package {
+// load loads and type-checks the given packages together with their tests,
+// honoring go.mod, go.work and build flags of the configured directory.
+func load(opts *Options, patterns ...string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
+		Dir:        opts.Dir,
+		Env:        opts.env(),
+		BuildFlags: opts.buildFlags(),
+		Tests:      true,
+	}
+	return packages.Load(cfg, patterns...)
+}
+
+// lookupPackage looks up a package with the given import path. A test variant
+// of the package, augmented with in-package test files, is preferred over the
+// plain one, unless only the former has errors, e.g. due to broken test files.
+// Variants built for tests of other packages are used as the last resort.
+func lookupPackage(pkgs []*packages.Package, path string) *packages.Package {
+	var plain, test, other *packages.Package
+	for _, pkg := range pkgs {
+		if pkg.PkgPath != path || pkg.Types == nil {
 			continue
 		}
-		tmp, ok := obj.Type().(*types.Named)
-		if !ok {
-			continue
+		switch pkg.ID {
+		case path:
+			plain = pkg
+		case path + " [" + path + ".test]":
+			test = pkg
+		default:
+			if other == nil {
+				other = pkg
+			}
 		}
-		if tmp.Obj() == obj {
-			typ = tmp
-			break
+	}
+	switch {
+	case test != nil && (plain == nil || len(test.Errors) == 0 || len(plain.Errors) != 0):
+		return test
+	case plain != nil:
+		return plain
+	}
+	return other
+}
+
+func notLoadedErr(pkgs []*packages.Package, path string) error {
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) != 0 {
+			return fmt.Errorf("unable to load package %q: %s", path, pkg.Errors[0])
 		}
 	}
-	if typ == nil {
-		return nil, notFoundErr(opts)
+	return fmt.Errorf("parsing successful, but package %q not found", path)
+}
+
+func buildResultForPkg(pkg *packages.Package, pkgs []*packages.Package, opts *Options) (*Result, error) {
+	obj, ok := pkg.Types.Scope().Lookup(opts.Query.TypeName).(*types.TypeName)
+	if !ok {
+		return nil, typeNotFoundErr(opts)
+	}
+	typ, ok := obj.Type().(*types.Named)
+	if !ok || typ.Obj() != obj {
+		return nil, typeNotFoundErr(opts)
 	}
-	var inter Interface
-	var methods = make(map[string]*types.Func)
-	collectMethods(methods, typ, 0, nil)
-	for _, method := range methods {
-		// TODO(rjeczalik): read rune
-		isLowerLetter := method.Name()[0] == '_' || unicode.IsLower(rune(method.Name()[0]))
-		if isLowerLetter && !opts.Unexported {
-			continue
+	var (
+		inter Interface
+		err   error
+	)
+	res := &Result{
+		Package:    pkg.Types.Name(),
+		ImportPath: trimVendorPath(pkg.Types.Path()),
+		TypeName:   obj.Name(),
+		Kind:       kindOf(typ.Underlying()),
+	}
+	switch tparams := typ.TypeParams(); {
+	case len(opts.Query.TypeArgs) != 0:
+		if tparams.Len() == 0 {
+			return nil, fmt.Errorf("type %q (package %q) is not generic",
+				opts.Query.TypeName, opts.Query.Package)
 		}
-		sig, ok := method.Type().(*types.Signature)
-		if !ok {
-			continue
+		targs, err := evalTypeArgs(opts.Query, pkg, pkgs)
+		if err != nil {
+			return nil, err
 		}
-		ins := sig.Params()
-		outs := sig.Results()
-		fn := Func{
-			Name:       method.Name(),
-			Ins:        make([]Type, ins.Len()),
-			Outs:       make([]Type, outs.Len()),
-			IsVariadic: sig.Variadic(),
+		inst, err := types.Instantiate(nil, typ, targs, true)
+		if err != nil {
+			return nil, fmt.Errorf("unable to instantiate %q: %w", opts.Query.TypeName, err)
 		}
-		for i := range fn.Ins {
-			fn.Ins[i] = newType(ins.At(i))
-			fixup(&fn.Ins[i], opts.Query)
+		typ = inst.(*types.Named)
+	case tparams.Len() != 0:
+		// Instantiate the generic type with its own type parameters, so
+		// the method signatures refer to them instead of to the receiver
+		// type parameters, which may be named differently.
+		targs := make([]types.Type, tparams.Len())
+		inter.TypeParams = make(TypeParams, tparams.Len())
+		for i := range targs {
+			tp := tparams.At(i)
+			targs[i] = tp
+			if inter.TypeParams[i], err = newTypeParam(tp); err != nil {
+				return nil, err
+			}
 		}
-		for i := range fn.Outs {
-			fn.Outs[i] = newType(outs.At(i))
-			fixup(&fn.Outs[i], opts.Query)
+		inst, err := types.Instantiate(nil, typ, targs, false)
+		if err != nil {
+			return nil, err
 		}
-		inter = append(inter, fn)
+		typ = inst.(*types.Named)
 	}
-	if len(inter) == 0 {
-		return nil, notFoundErr(opts)
+	inter.Diagnostics = diagnostics(pkg)
+	invalid := invalidEmbeds(typ)
+	var comments *commentReader
+	if opts.Comments {
+		comments = newCommentReader(pkg)
 	}
-	sort.Sort(funcs(inter))
-	return inter, nil
-}
-
-func collectMethods(methods map[string]*types.Func, typ *types.Named, depth int, orig types.Type) {
-	if orig == nil {
-		orig = typ
+	sels, err := filterMethods(collectMethods(typ, opts), opts)
+	if err != nil {
+		return nil, err
 	}
-	// TODO(rjeczalik): recursive types support
-	if depth > 128 {
-		panic("recursive types not supported: " + orig.String())
+	var embeds []types.Type
+	if opts.KeepEmbedded {
+		embeds, sels = collectEmbeds(typ, sels)
 	}
-	for i := 0; i < typ.NumMethods(); i++ {
-		method := typ.Method(i)
-		if _, ok := methods[method.Name()]; ok {
-			continue
+	if len(opts.Compact) != 0 {
+		compacted, rest, err := compactMethods(typ, sels, pkg, opts)
+		if err != nil {
+			return nil, err
 		}
-		methods[method.Name()] = method
+		embeds, sels = append(embeds, compacted...), rest
 	}
-	if typ, ok := typ.Underlying().(*types.Struct); ok {
-		for i := 0; i < typ.NumFields(); i++ {
-			field := typ.Field(i)
-			if !field.Anonymous() {
-				continue
-			}
-			typ := field.Type()
-			if p, ok := typ.(*types.Pointer); ok {
-				typ = p.Elem()
-			}
-			if named, ok := typ.(*types.Named); ok {
-				collectMethods(methods, named, depth+1, orig)
+	if len(embeds) != 0 {
+		inter.Embeds = make([]Type, len(embeds))
+		for i, embed := range embeds {
+			if err := inter.Embeds[i].setFromType(embed, make(map[types.Type]bool)); err != nil {
+				return nil, err
 			}
 		}
+		sort.Slice(inter.Embeds, func(i, j int) bool {
+			return inter.Embeds[i].String() < inter.Embeds[j].String()
+		})
+	}
+	sort.Slice(sels, func(i, j int) bool {
+		return sels[i].Obj().Name() < sels[j].Obj().Name()
+	})
+	for _, sel := range sels {
+		method := sel.Obj().(*types.Func)
+		sig, ok := method.Type().(*types.Signature)
+		if !ok {
+			continue
+		}
+		fn, err := newFunc(method.Name(), sig, make(map[types.Type]bool))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method.Name(), err)
+		}
+		bad := false
+		fn.walk(func(typ *Type) {
+			bad = bad || typ.invalid()
+		})
+		if bad {
+			invalid = append(invalid, fn.Name)
+		}
+		fn.Pos = pkg.Fset.Position(method.Origin().Pos())
+		if comments != nil {
+			fn.Doc = comments.doc(method)
+		}
+		inter.Methods = append(inter.Methods, fn)
+		res.Methods = append(res.Methods, newMethod(typ, sel, pkg.Fset))
+	}
+	if opts.Strict && len(invalid) != 0 {
+		return nil, invalidTypeErr(opts, invalid, inter.Diagnostics)
+	}
+	if len(inter.Methods) == 0 && len(inter.Embeds) == 0 {
+		return nil, noMethodsErr(opts)
 	}
+	res.Interface = inter
+	return res, nil
 }
@@ -1,6 +1,11 @@
 package interfaces_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/rjeczalik/interfaces"
@@ -16,6 +21,16 @@ func TestParseQuery(t *testing.T) {
 			Package:  "github.com/rjeczalik/interfaces",
 			TypeName: "Query",
 		},
+		`example.com/cache.LRU[string,*example.com/user.User]`: {
+			Package:  "example.com/cache",
+			TypeName: "LRU",
+			TypeArgs: []string{"string", "*example.com/user.User"},
+		},
+		`example.com/cache.LRU[map[string]int, func(a, b int) error]`: {
+			Package:  "example.com/cache",
+			TypeName: "LRU",
+			TypeArgs: []string{"map[string]int", "func(a, b int) error"},
+		},
 	}
 	for raw, query := range cases {
 		q, err := interfaces.ParseQuery(raw)
@@ -29,5 +44,164 @@ func TestParseQuery(t *testing.T) {
 		if q.TypeName != query.TypeName {
 			t.Errorf("ParseQuery(%q): want type=%q; got %q", raw, query.TypeName, q.TypeName)
 		}
+		if !reflect.DeepEqual(q.TypeArgs, query.TypeArgs) {
+			t.Errorf("ParseQuery(%q): want type args=%q; got %q", raw, query.TypeArgs, q.TypeArgs)
+		}
+	}
+}
+
+func TestParseQueryError(t *testing.T) {
+	cases := []string{
+		`os`,
+		`os.`,
+		`.File`,
+		`example.com/cache.LRU[string`,
+		`example.com/cache.LRU[string,]`,
+		`example.com/cache.LRU[map[string]]int]`,
+	}
+	for _, raw := range cases {
+		if q, err := interfaces.ParseQuery(raw); err == nil {
+			t.Errorf("ParseQuery(%q): want err != nil; got %+v", raw, q)
+		}
+	}
+}
+
+func TestNewWithOptionsError(t *testing.T) {
+	query := func(pkg, name string) *interfaces.Options {
+		return &interfaces.Options{Query: &interfaces.Query{Package: pkg, TypeName: name}}
+	}
+	excluded := query("github.com/rjeczalik/interfaces", "ExampleBar")
+	excluded.Exclude = []string{"*"}
+	cases := map[string]struct {
+		opts *interfaces.Options
+		err  error
+	}{
+		"nil options":         {opts: nil},
+		"nil query":           {opts: &interfaces.Options{}},
+		"type not found":      {opts: query("os", "NoSuchType"), err: interfaces.ErrTypeNotFound},
+		"test type not found": {opts: query("github.com/rjeczalik/interfaces", "NoSuchType"), err: interfaces.ErrTypeNotFound},
+		"no methods":          {opts: query("github.com/rjeczalik/interfaces", "ExampleFoo"), err: interfaces.ErrNoMethods},
+		"all excluded":        {opts: excluded, err: interfaces.ErrNoMethods},
+	}
+	for name, cas := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := interfaces.NewWithOptions(cas.opts)
+			if err == nil {
+				t.Fatal("want err != nil")
+			}
+			if cas.err != nil && !errors.Is(err, cas.err) {
+				t.Errorf("got %q; want %q", err, cas.err)
+			}
+		})
+	}
+}
+
+func TestNewWithOptionsDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/broken\n\ngo 1.26\n",
+		"t.go":   "package broken\n\ntype T struct{}\n\nfunc (T) A(Undefined) int { return 0 }\n\nfunc (T) B() string { return 1 }\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{Package: "example.com/broken", TypeName: "T"},
+		Dir:   dir,
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		t.Fatalf("NewWithOptions()=%s", err)
+	}
+	var diags []string
+	for _, d := range i.Diagnostics {
+		diags = append(diags, d.Msg)
+	}
+	wantDiags := []string{
+		"undefined: Undefined",
+		"cannot use 1 (untyped int constant) as string value in return statement",
+	}
+	if !reflect.DeepEqual(diags, wantDiags) {
+		t.Errorf("got %q; want %q", diags, wantDiags)
+	}
+	if got, want := i.Methods[0].String(), "A(invalid type) int"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	opts.Strict = true
+	if _, err := interfaces.NewWithOptions(opts); !errors.Is(err, interfaces.ErrInvalidType) {
+		t.Errorf("got %v; want %v", err, interfaces.ErrInvalidType)
+	}
+	// Errors in function bodies do not fail the strict mode.
+	opts.Exclude = []string{"A"}
+	if _, err := interfaces.NewWithOptions(opts); err != nil {
+		t.Errorf("NewWithOptions()=%s", err)
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{
+			Package:  "github.com/rjeczalik/interfaces",
+			TypeName: "ExampleStream",
+		},
+	}
+	res, err := interfaces.NewResult(opts)
+	if err != nil {
+		t.Fatalf("NewResult()=%s", err)
+	}
+	if res.Kind != interfaces.KindInterface {
+		t.Errorf("got %s; want %s", res.Kind, interfaces.KindInterface)
+	}
+	want := map[string]string{
+		"Close":  "ExampleStream -> io.ReadWriteCloser -> io.Closer",
+		"Flush":  "ExampleStream",
+		"Read":   "ExampleStream -> io.ReadWriteCloser -> io.Reader",
+		"String": "ExampleStream -> fmt.Stringer",
+		"Write":  "ExampleStream -> io.ReadWriteCloser -> io.Writer",
+	}
+	if len(res.Methods) != len(want) || len(res.Interface.Methods) != len(want) {
+		t.Fatalf("got %d methods; want %d", len(res.Methods), len(want))
+	}
+	for i, m := range res.Methods {
+		if m.Name != res.Interface.Methods[i].Name {
+			t.Errorf("got %q; want %q", m.Name, res.Interface.Methods[i].Name)
+		}
+		if got := m.EmbeddingPath(res.TypeName); got != want[m.Name] {
+			t.Errorf("%s: got %q; want %q", m.Name, got, want[m.Name])
+		}
+		if !m.Pos.IsValid() || !m.RecvPos.IsValid() {
+			t.Errorf("%s: got invalid position %v (receiver %v)", m.Name, m.Pos, m.RecvPos)
+		}
+	}
+	if got := filepath.Base(res.Methods[1].Pos.Filename); got != "example_test.go" {
+		t.Errorf("got %q; want %q", got, "example_test.go")
+	}
+}
+
+func TestNewWithOptionsReceiverInterface(t *testing.T) {
+	var warnings []string
+	opts := &interfaces.Options{
+		Query:    &interfaces.Query{Package: "io", TypeName: "ReadWriteCloser"},
+		Receiver: interfaces.ReceiverPointer,
+		Warnf: func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		},
+	}
+	i, err := interfaces.NewWithOptions(opts)
+	if err != nil {
+		t.Fatalf("NewWithOptions()=%s", err)
+	}
+	var methods []string
+	for _, fn := range i.Methods {
+		methods = append(methods, fn.Name)
+	}
+	if want := []string{"Close", "Read", "Write"}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("got %q; want %q", methods, want)
+	}
+	if want := []string{"receiver pointer ignored for interface type ReadWriteCloser"}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("got %q; want %q", warnings, want)
 	}
 }
@@ -0,0 +1,438 @@
+package interfaces
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/build"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Loader builds interfaces and structs for types of Go packages, caching
+// loaded packages between calls. Cached packages are loaded again, when any
+// of their files, or files of the packages they depend on within the main
+// module (or workspace), changed.
+//
+// A Loader keeps packages loaded for a bounded number of distinct lists of
+// packages, see SetMaxEntries; least recently used ones are dropped first.
+//
+// A Loader is safe for concurrent use.
+type Loader struct {
+	opts    Options
+	mu      sync.Mutex
+	max     int                // maximum number of entries; unbounded if not positive
+	entries map[string]*loaded // loaded packages by patterns
+	clock   uint64             // incremented with each use of an entry
+}
+
+// DefaultLoaderMaxEntries is the maximum number of distinct lists of packages
+// a Loader keeps loaded by default.
+const DefaultLoaderMaxEntries = 16
+
+// NewLoader gives a Loader, which uses the given Options for all the queries,
+// except for their Query field. If opts is nil, default Options are used.
+func NewLoader(opts *Options) *Loader {
+	l := &Loader{
+		max:     DefaultLoaderMaxEntries,
+		entries: make(map[string]*loaded),
+	}
+	if opts != nil {
+		l.opts = *opts
+	}
+	l.opts.Query = nil
+	return l
+}
+
+// SetMaxEntries sets the maximum number of distinct lists of packages the
+// Loader keeps loaded, DefaultLoaderMaxEntries by default. If n is not
+// positive, the number is not limited.
+func (l *Loader) SetMaxEntries(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = n
+	l.evict()
+}
+
+// Interface builds an interface definition for a type specified by
+// the query, like NewWithOptions does.
+func (l *Loader) Interface(q *Query) (Interface, error) {
+	if err := q.valid(); err != nil {
+		return Interface{}, fmt.Errorf("invalid query: %w", err)
+	}
+	opts := l.opts
+	opts.Query = q
+	var inter Interface
+	err := l.do(q.Packages(), func(pkgs []*packages.Package) error {
+		res, err := buildResultFrom(pkgs, &opts)
+		if err != nil {
+			return err
+		}
+		inter = res.Interface
+		return nil
+	})
+	return inter, err
+}
+
+// Struct builds a struct definition for a struct type specified by
+// the query. Unexported fields are skipped, unless Options.Unexported
+// is true.
+func (l *Loader) Struct(q *Query) (Struct, error) {
+	if err := q.valid(); err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	opts := l.opts
+	opts.Query = q
+	var s Struct
+	err := l.do(q.Packages(), func(pkgs []*packages.Package) (err error) {
+		s, err = buildStructFrom(pkgs, &opts)
+		return err
+	})
+	return s, err
+}
+
+// do calls fn with packages loaded for the patterns. Calls for the same
+// patterns are serialized, as building types from them is not safe for
+// concurrent use.
+func (l *Loader) do(patterns []string, fn func([]*packages.Package) error) error {
+	patterns = append([]string(nil), patterns...)
+	sort.Strings(patterns)
+	key := strings.Join(patterns, " ")
+	l.mu.Lock()
+	e, ok := l.entries[key]
+	if !ok {
+		e = new(loaded)
+		l.entries[key] = e
+	}
+	l.clock++
+	e.used = l.clock
+	l.evict()
+	l.mu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pkgs == nil || e.stale() {
+		if err := e.load(&l.opts, patterns); err != nil {
+			return err
+		}
+	}
+	return fn(e.pkgs)
+}
+
+// evict drops least recently used entries above the limit. Entries still in
+// use by other calls are freed once the calls return.
+func (l *Loader) evict() {
+	for l.max > 0 && len(l.entries) > l.max {
+		var (
+			oldest string
+			used   uint64
+		)
+		for key, e := range l.entries {
+			if used == 0 || e.used < used {
+				oldest, used = key, e.used
+			}
+		}
+		delete(l.entries, oldest)
+	}
+}
+
+// loaded holds packages loaded for a list of patterns, together with stamps
+// of the files they were loaded from.
+type loaded struct {
+	mu     sync.Mutex
+	pkgs   []*packages.Package
+	stamps map[string]*stamp // by file or directory path
+	used   uint64            // time of last use by the Loader clock; guarded by Loader.mu
+}
+
+// stamp identifies a version of a file or a directory.
+type stamp struct {
+	modTime time.Time
+	size    int64
+	hash    []byte // nil for directories and missing files
+	missing bool   // whether the file did not exist
+}
+
+func (e *loaded) load(opts *Options, patterns []string) error {
+	// Stale packages are dropped first, so they are not kept in memory
+	// together with the new ones.
+	e.pkgs, e.stamps = nil, nil
+	pkgs, err := load(opts, patterns...)
+	if err != nil {
+		return err
+	}
+	deps, err := loadDeps(opts, patterns)
+	if err != nil {
+		return err
+	}
+	stamps := make(map[string]*stamp)
+	for _, path := range watchedFiles(opts, deps) {
+		stamps[path] = newStamp(path)
+	}
+	e.pkgs, e.stamps = pkgs, stamps
+	return nil
+}
+
+// loadDeps lists the packages and all their dependencies. It is a separate,
+// cheap query, as loading them together with types would type-check all
+// the packages from source.
+func loadDeps(opts *Options, patterns []string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports |
+			packages.NeedDeps | packages.NeedModule,
+		Dir:        opts.Dir,
+		Env:        opts.env(),
+		BuildFlags: opts.buildFlags(),
+		Tests:      true,
+	}
+	return packages.Load(cfg, patterns...)
+}
+
+// watchedFiles gives sorted paths of files which changes may affect types of
+// the packages: their source files and ones of packages they depend on,
+// directories these are in, as files may be added to or removed from them,
+// and go.mod, go.sum, go.work and vendor/modules.txt files of the modules and
+// the workspace. Packages of the standard library and of the module cache
+// never change; the latter are replaced only with a change of go.mod.
+func watchedFiles(opts *Options, roots []*packages.Package) []string {
+	var (
+		env      = opts.env()
+		goroot   = build.Default.GOROOT + string(filepath.Separator)
+		modcache = modCache(env) + string(filepath.Separator)
+		paths    = make(map[string]bool)
+	)
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		files := append(pkg.GoFiles[:len(pkg.GoFiles):len(pkg.GoFiles)], pkg.OtherFiles...)
+		if len(files) == 0 || strings.HasPrefix(files[0], goroot) || strings.HasPrefix(files[0], modcache) {
+			return
+		}
+		for _, file := range files {
+			paths[file] = true
+			paths[filepath.Dir(file)] = true
+		}
+		if m := pkg.Module; m != nil && m.GoMod != "" && !strings.HasPrefix(m.GoMod, modcache) {
+			dir := filepath.Dir(m.GoMod)
+			paths[m.GoMod] = true
+			paths[filepath.Join(dir, "go.sum")] = true
+			paths[filepath.Join(dir, "vendor", "modules.txt")] = true
+		}
+	})
+	if work := goWork(opts.Dir, env); work != "" {
+		paths[work] = true
+		paths[work+".sum"] = true
+		paths[filepath.Join(filepath.Dir(work), "vendor", "modules.txt")] = true
+	}
+	list := make([]string, 0, len(paths))
+	for path := range paths {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// getenv gives the value of the environment variable, which the go command
+// is run with.
+func getenv(env []string, key string) string {
+	if env == nil {
+		return os.Getenv(key)
+	}
+	for i := len(env) - 1; i >= 0; i-- {
+		if v, ok := strings.CutPrefix(env[i], key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// modCache gives the module cache directory.
+func modCache(env []string) string {
+	if dir := getenv(env, "GOMODCACHE"); dir != "" {
+		return filepath.Clean(dir)
+	}
+	gopath := getenv(env, "GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// goWork gives the path of the go.work file the go command run in the
+// directory uses, or an empty string if there is none.
+func goWork(dir string, env []string) string {
+	switch work := getenv(env, "GOWORK"); work {
+	case "off":
+		return ""
+	case "":
+	default:
+		return work
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.work")); err == nil && !fi.IsDir() {
+			return filepath.Join(dir, "go.work")
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// stale tells whether any of the stamped files changed since the packages
+// were loaded. Files which modification time changed, but the content did
+// not, are stamped again.
+func (e *loaded) stale() bool {
+	for path, s := range e.stamps {
+		fi, err := os.Stat(path)
+		switch {
+		case err != nil && s.missing:
+			continue
+		case err != nil || s.missing:
+			return true
+		}
+		if fi.ModTime().Equal(s.modTime) && fi.Size() == s.size {
+			continue
+		}
+		if s.hash == nil {
+			return true
+		}
+		fresh := newStamp(path)
+		if !bytes.Equal(fresh.hash, s.hash) {
+			return true
+		}
+		e.stamps[path] = fresh
+	}
+	return false
+}
+
+// newStamp stamps the file or directory. A file which can't be read is
+// stamped as missing.
+func newStamp(path string) *stamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return &stamp{missing: true}
+	}
+	s := &stamp{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+	}
+	if fi.IsDir() {
+		return s
+	}
+	p, err := os.ReadFile(path)
+	if err != nil {
+		return &stamp{missing: true}
+	}
+	hash := sha256.Sum256(p)
+	s.hash = hash[:]
+	return s
+}
+
+// SourceHashes gives, for each of the options, a hash of the files which
+// changes may affect the interface built for the options, e.g. for use as
+// a part of a cache key. Unlike the interfaces themselves, the hashes are
+// computed without type-checking the packages.
+//
+// The go command is run with settings of the first options.
+func SourceHashes(opts ...*Options) ([]string, error) {
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	var patterns []string
+	for i, o := range opts {
+		if o == nil {
+			return nil, fmt.Errorf("interfaces: called SourceHashes with nil Options %d", i)
+		}
+		if err := o.Query.valid(); err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		patterns = append(patterns, o.Query.Packages()...)
+		patterns = append(patterns, o.Compact...)
+	}
+	deps, err := loadDeps(opts[0], patterns)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string][]*packages.Package)
+	for _, pkg := range deps {
+		byPath[pkg.PkgPath] = append(byPath[pkg.PkgPath], pkg)
+	}
+	stamps := make(map[string]*stamp)
+	hashes := make([]string, len(opts))
+	for i, o := range opts {
+		var roots []*packages.Package
+		for _, path := range append(o.Query.Packages(), o.Compact...) {
+			roots = append(roots, byPath[path]...)
+			roots = append(roots, byPath[path+"_test"]...)
+		}
+		h := sha256.New()
+		for _, path := range watchedFiles(opts[0], roots) {
+			s, ok := stamps[path]
+			if !ok {
+				s = newStamp(path)
+				stamps[path] = s
+			}
+			// Directories are covered by the list of files.
+			if s.hash != nil || s.missing {
+				fmt.Fprintf(h, "%s %x\n", path, s.hash)
+			}
+		}
+		hashes[i] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes, nil
+}
+
+func buildStructFrom(pkgs []*packages.Package, opts *Options) (Struct, error) {
+	q := opts.Query
+	// Like for interfaces, the type may be defined in an external test package.
+	for _, path := range []string{q.Package, q.Package + "_test"} {
+		pkg := lookupPackage(pkgs, path)
+		if pkg == nil {
+			continue
+		}
+		obj, ok := pkg.Types.Scope().Lookup(q.TypeName).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		typ := obj.Type()
+		if len(q.TypeArgs) != 0 {
+			targs, err := evalTypeArgs(q, pkg, pkgs)
+			if err != nil {
+				return nil, err
+			}
+			if typ, err = types.Instantiate(nil, typ, targs, true); err != nil {
+				return nil, fmt.Errorf("unable to instantiate %q: %w", q.TypeName, err)
+			}
+		}
+		t, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return nil, fmt.Errorf("type %q (package %q) is not a struct", q.TypeName, q.Package)
+		}
+		var st Type
+		if err := st.setFromType(t, make(map[types.Type]bool)); err != nil {
+			return nil, fmt.Errorf("%s: %w", q.TypeName, err)
+		}
+		var s Struct
+		for i, f := range st.Fields {
+			if t.Field(i).Exported() || opts.Unexported {
+				s = append(s, f)
+			}
+		}
+		return s, nil
+	}
+	if lookupPackage(pkgs, q.Package) == nil {
+		return nil, notLoadedErr(pkgs, q.Package)
+	}
+	return nil, typeNotFoundErr(opts)
+}
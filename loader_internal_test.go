@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestLoaderEvict(t *testing.T) {
+	l := NewLoader(nil)
+	l.SetMaxEntries(2)
+	nop := func([]*packages.Package) error { return nil }
+	for _, pkg := range []string{"errors", "io", "errors", "unicode"} {
+		if err := l.do([]string{pkg}, nop); err != nil {
+			t.Fatalf("do(%q)=%s", pkg, err)
+		}
+	}
+	keys := func() []string {
+		var keys []string
+		for key := range l.entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	if got, want := keys(), []string{"errors", "unicode"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	l.SetMaxEntries(1)
+	if got, want := keys(), []string{"unicode"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	l.SetMaxEntries(0)
+	for _, pkg := range []string{"errors", "io"} {
+		if err := l.do([]string{pkg}, nop); err != nil {
+			t.Fatalf("do(%q)=%s", pkg, err)
+		}
+	}
+	if got, want := keys(), []string{"errors", "io", "unicode"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package interfaces_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/rjeczalik/interfaces"
+)
+
+func TestLoader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+	methods := func(i interfaces.Interface) []string {
+		var s []string
+		for _, fn := range i.Methods {
+			s = append(s, fn.String())
+		}
+		return s
+	}
+	write("go.mod", "module example.com/loader\n\ngo 1.26\n")
+	write("a.go", "package loader\n\ntype T struct {\n\tName string `json:\"name\"`\n\tid int\n}\n\nfunc (T) A() int { return 0 }\n")
+
+	l := interfaces.NewLoader(&interfaces.Options{Dir: dir})
+	q := &interfaces.Query{Package: "example.com/loader", TypeName: "T"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	inters := make([]interfaces.Interface, len(errs))
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			inters[i], errs[i] = l.Interface(q)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Interface()=%s", err)
+		}
+		if got, want := methods(inters[i]), []string{"A() int"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %q; want %q", got, want)
+		}
+	}
+
+	s, err := l.Struct(q)
+	if err != nil {
+		t.Fatalf("Struct()=%s", err)
+	}
+	want := interfaces.Struct{{
+		Name: "Name",
+		Type: interfaces.Type{Name: "string"},
+		Tag:  `json:"name"`,
+	}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("got %+v; want %+v", s, want)
+	}
+
+	write("a.go", "package loader\n\ntype T struct{}\n\nfunc (T) A() string { return \"\" }\n")
+	write("b.go", "package loader\n\nfunc (*T) B() {}\n")
+	i, err := l.Interface(q)
+	if err != nil {
+		t.Fatalf("Interface()=%s", err)
+	}
+	if got, want := methods(i), []string{"A() string", "B()"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
+
+func TestSourceHashes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatalf("MkdirAll()=%s", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("WriteFile()=%s", err)
+		}
+	}
+	opts := &interfaces.Options{
+		Query: &interfaces.Query{Package: "example.com/hashes", TypeName: "T"},
+		Dir:   dir,
+		Env:   append(os.Environ(), "GOWORK=", "GOFLAGS="),
+	}
+	seen := make(map[string]string)
+	hash := func(change string) {
+		t.Helper()
+		hashes, err := interfaces.SourceHashes(opts)
+		if err != nil {
+			t.Fatalf("%s: SourceHashes()=%s", change, err)
+		}
+		if prev, ok := seen[hashes[0]]; ok {
+			t.Errorf("%s: got the same hash as for %s", change, prev)
+		}
+		seen[hashes[0]] = change
+	}
+	write("go.mod", "module example.com/hashes\n\ngo 1.26\n")
+	write("a.go", "package hashes\n\ntype T struct{}\n")
+	hash("initial")
+	write("a.go", "package hashes\n\ntype T struct{ A int }\n")
+	hash("source file")
+	write("vendor/modules.txt", "")
+	hash("vendor/modules.txt")
+	if err := os.RemoveAll(filepath.Join(dir, "vendor")); err != nil {
+		t.Fatalf("RemoveAll()=%s", err)
+	}
+	write("go.work", "go 1.26\n\nuse .\n")
+	hash("go.work")
+}
+
+func TestSourceHashesError(t *testing.T) {
+	cases := map[string][]*interfaces.Options{
+		"nil options":   {nil},
+		"nil query":     {{}},
+		"invalid query": {{Query: &interfaces.Query{Package: "io"}}},
+		"second nil":    {{Query: &interfaces.Query{Package: "io", TypeName: "Reader"}}, nil},
+	}
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := interfaces.SourceHashes(opts...); err == nil {
+				t.Fatal("want err != nil")
+			}
+		})
+	}
+}
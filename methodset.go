@@ -0,0 +1,253 @@
+package interfaces
+
+import (
+	"errors"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Receiver describes which method set of a type T an interface is built from.
+type Receiver uint8
+
+// Method sets of a type T.
+const (
+	ReceiverAny     Receiver = iota // methods of T and *T
+	ReceiverValue                   // methods of T
+	ReceiverPointer                 // methods of *T
+)
+
+var receivers = [...]string{
+	ReceiverAny:     "any",
+	ReceiverValue:   "value",
+	ReceiverPointer: "pointer",
+}
+
+// String gives a text representation of the receiver.
+func (r Receiver) String() string {
+	if int(r) < len(receivers) {
+		return receivers[r]
+	}
+	return "invalid"
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (r Receiver) MarshalText() ([]byte, error) {
+	if int(r) >= len(receivers) {
+		return nil, errors.New("invalid receiver")
+	}
+	return []byte(receivers[r]), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (r *Receiver) UnmarshalText(p []byte) error {
+	for i, s := range receivers {
+		if s == string(p) {
+			*r = Receiver(i)
+			return nil
+		}
+	}
+	return errors.New("invalid receiver: " + string(p))
+}
+
+// collectMethods gives methods of the named type, the way the compiler sees
+// them. These are methods of T, *T or both, depending on opts.Receiver,
+// including ones promoted from embedded fields, where the shallowest one wins.
+// Unexported methods are skipped, unless opts.Unexported is true. For an
+// interface type, opts.Receiver is ignored.
+//
+// Methods promoted from more than one embedded field at the same depth are
+// ambiguous and as such do not belong to the method set; each of them
+// is reported with opts.Warnf. So are methods which are excluded as they
+// do not belong to the chosen method set.
+func collectMethods(typ *types.Named, opts *Options) []*types.Selection {
+	var (
+		sels     []*types.Selection
+		excluded []string
+		names    = make(map[string]bool)
+		chosen   *types.MethodSet // nil for both T and *T
+	)
+	receiver := opts.Receiver
+	if _, ok := typ.Underlying().(*types.Interface); ok && receiver != ReceiverAny {
+		// A pointer to an interface has no methods, while methods of an
+		// interface type are the same for any receiver.
+		opts.warnf("receiver %s ignored for interface type %s", receiver, typ.Obj().Name())
+		receiver = ReceiverAny
+	}
+	switch receiver {
+	case ReceiverValue:
+		chosen = types.NewMethodSet(typ)
+	case ReceiverPointer:
+		chosen = types.NewMethodSet(types.NewPointer(typ))
+	}
+	for _, t := range []types.Type{typ, types.NewPointer(typ)} {
+		mset := types.NewMethodSet(t)
+		for i := 0; i < mset.Len(); i++ {
+			sel := mset.At(i)
+			name := sel.Obj().Name()
+			if names[name] || !token.IsExported(name) && !opts.Unexported {
+				continue
+			}
+			names[name] = true
+			if chosen == nil || chosen.Lookup(sel.Obj().Pkg(), name) != nil {
+				sels = append(sels, sel)
+			} else {
+				excluded = append(excluded, name)
+			}
+		}
+	}
+	if len(excluded) != 0 {
+		opts.warnf("methods not in the method set of %s: %s",
+			receiverType(typ, receiver), strings.Join(excluded, ", "))
+	}
+	for _, method := range embeddedMethods(typ, make(map[types.Type]bool)) {
+		if names[method.Name()] {
+			continue
+		}
+		obj, index, _ := types.LookupFieldOrMethod(typ, true, method.Pkg(), method.Name())
+		if obj == nil && index != nil {
+			opts.warnf("ambiguous selector %s.%s", typ.Obj().Name(), method.Name())
+		}
+		names[method.Name()] = true
+	}
+	return sels
+}
+
+func receiverType(typ *types.Named, r Receiver) string {
+	if r == ReceiverPointer {
+		return "*" + typ.Obj().Name()
+	}
+	return typ.Obj().Name()
+}
+
+// embeddedMethods gives methods of all the types embedded by typ, directly
+// or not. The seen map holds already visited types, so types embedding
+// themselves are visited once.
+func embeddedMethods(typ types.Type, seen map[types.Type]bool) []*types.Func {
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+	s, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	var methods []*types.Func
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		if !field.Embedded() {
+			continue
+		}
+		typ := field.Type()
+		if p, ok := typ.(*types.Pointer); ok {
+			typ = p.Elem()
+		}
+		if named, ok := types.Unalias(typ).(*types.Named); ok {
+			for j := 0; j < named.NumMethods(); j++ {
+				methods = append(methods, named.Method(j))
+			}
+		}
+		if iface, ok := typ.Underlying().(*types.Interface); ok {
+			for j := 0; j < iface.NumMethods(); j++ {
+				methods = append(methods, iface.Method(j))
+			}
+		}
+		methods = append(methods, embeddedMethods(typ, seen)...)
+	}
+	return methods
+}
+
+// collectEmbeds splits the methods into interfaces embedded by the named
+// type and the remaining methods. An interface, embedded either in an
+// interface type or as a field of a struct type, is kept only if all of its
+// methods are present in sels, so it is flattened if any were filtered out
+// or shadowed by a method with a different signature. When an embedded
+// interface is flattened, the interfaces it embeds are tried instead.
+//
+// Only interfaces that can be referred to from other packages are kept.
+func collectEmbeds(typ *types.Named, sels []*types.Selection) ([]types.Type, []*types.Selection) {
+	var candidates []types.Type
+	switch u := typ.Underlying().(type) {
+	case *types.Interface:
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			candidates = append(candidates, u.EmbeddedType(i))
+		}
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if field := u.Field(i); field.Embedded() {
+				candidates = append(candidates, field.Type())
+			}
+		}
+	}
+	byName := make(map[string]*types.Selection, len(sels))
+	for _, sel := range sels {
+		byName[sel.Obj().Name()] = sel
+	}
+	var (
+		embeds []types.Type
+		embed  func(types.Type)
+	)
+	embed = func(t types.Type) {
+		iface, ok := t.Underlying().(*types.Interface)
+		if !ok || iface.NumMethods() == 0 || !iface.IsMethodSet() {
+			return
+		}
+		if !embeddable(t) || !hasMethods(byName, iface) {
+			for i := 0; i < iface.NumEmbeddeds(); i++ {
+				embed(iface.EmbeddedType(i))
+			}
+			return
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			delete(byName, iface.Method(i).Name())
+		}
+		embeds = append(embeds, t)
+	}
+	for _, t := range candidates {
+		embed(t)
+	}
+	var rest []*types.Selection
+	for _, sel := range sels {
+		if byName[sel.Obj().Name()] != nil {
+			rest = append(rest, sel)
+		}
+	}
+	return embeds, rest
+}
+
+// embeddable tells whether the type is a named one, which can be referred
+// to from other packages, like io.Reader or error.
+func embeddable(t types.Type) bool {
+	var obj *types.TypeName
+	switch t := t.(type) {
+	case *types.Named:
+		obj = t.Obj()
+	case *types.Alias:
+		obj = t.Obj()
+	default:
+		return false
+	}
+	return obj.Pkg() == nil || obj.Exported()
+}
+
+// hasMethods tells whether all the methods of the interface are among
+// the selected ones, with identical signatures.
+func hasMethods(sels map[string]*types.Selection, iface *types.Interface) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		sel, ok := sels[method.Name()]
+		if !ok || !sameType(sel.Obj().Type(), method.Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameType tells whether the types are identical. Types of packages loaded
+// separately, e.g. one from source and the other from export data, are
+// never identical, thus they are compared by their package-qualified names;
+// in that case aliases are told apart from the types they denote.
+func sameType(x, y types.Type) bool {
+	return types.Identical(x, y) ||
+		types.TypeString(x, (*types.Package).Path) == types.TypeString(y, (*types.Package).Path)
+}
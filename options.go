@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"go/build"
+	"os"
 	"strings"
 )
 
@@ -11,12 +12,28 @@ var errSyntax = errors.New("query string syntax error")
 
 // Query represents a named type request.
 type Query struct {
-	TypeName string `json:"name,omitempty"`
-	Package  string `json:"package,omitempty"`
+	TypeName string   `json:"name,omitempty"`
+	Package  string   `json:"package,omitempty"`
+	TypeArgs []string `json:"typeArgs,omitempty"` // type arguments for a generic type
 }
 
 // ParseQuery gives new Query for the given query text.
+//
+// A generic type may be instantiated by appending type arguments to the
+// query, e.g. example.com/cache.LRU[string,*example.com/user.User]. Named
+// types used in type arguments must be qualified with full import paths.
 func ParseQuery(query string) (*Query, error) {
+	var targs []string
+	if i := strings.IndexByte(query, '['); i != -1 {
+		if !strings.HasSuffix(query, "]") {
+			return nil, fmt.Errorf("%w: type argument list is not terminated with ]", errSyntax)
+		}
+		var err error
+		if targs, err = splitTypeArgs(query[i+1 : len(query)-1]); err != nil {
+			return nil, err
+		}
+		query = query[:i]
+	}
 	idx := strings.LastIndex(query, ".")
 	if idx == -1 || query[:idx] == "" || query[idx+1:] == "" {
 		return nil, errors.New("generating source should be path/to/package.type")
@@ -25,8 +42,51 @@ func ParseQuery(query string) (*Query, error) {
 	return &Query{
 		Package:  query[:idx],
 		TypeName: query[idx+1:],
+		TypeArgs: targs,
 	}, nil
 }
+
+// splitTypeArgs splits comma-separated type arguments, ignoring commas
+// nested in brackets, e.g. in map[K]V or func(A, B) types.
+func splitTypeArgs(s string) ([]string, error) {
+	var (
+		targs []string
+		depth int
+		start int
+	)
+	for i, c := range s {
+		switch c {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("%w: unbalanced %q in type arguments", errSyntax, c)
+			}
+		case ',':
+			if depth == 0 {
+				targs = append(targs, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced brackets in type arguments", errSyntax)
+	}
+	targs = append(targs, strings.TrimSpace(s[start:]))
+	for _, targ := range targs {
+		if targ == "" {
+			return nil, fmt.Errorf("%w: empty type argument", errSyntax)
+		}
+	}
+	return targs, nil
+}
+
+// Packages gives import paths of the packages the query refers to, i.e.
+// the package of the type followed by packages of its type arguments.
+func (q *Query) Packages() []string {
+	return append([]string{q.Package}, typeArgPackages(q.TypeArgs)...)
+}
+
 func (q *Query) valid() error {
 	if q == nil {
 		return errors.New("query is nil")
@@ -37,28 +97,82 @@ func (q *Query) valid() error {
 	if q.TypeName == "" {
 		return errors.New("type name is empty")
 	}
+	for i, targ := range q.TypeArgs {
+		if targ == "" {
+			return fmt.Errorf("type argument %d is empty", i)
+		}
+	}
 	return nil
 }
 
 // Options is used for altering behavior of New() function.
 type Options struct {
-	Query      *Query         // a named type
-	Context    *build.Context // build context; see go/build godoc for details
-	Unexported bool           // whether to include unexported methods
+	Query        *Query         // a named type
+	Context      *build.Context // build context; see go/build godoc for details
+	Dir          string         // directory to run the go command in; current one if empty
+	BuildFlags   []string       // extra go command flags, e.g. -tags or -mod=vendor
+	Env          []string       // environment of the go command; os.Environ() if nil
+	Unexported   bool           // whether to include unexported methods
+	Comments     bool           // whether to read doc comments of methods
+	Receiver     Receiver       // method set to build the interface from; both T and *T by default
+	Include      []string       // patterns of method names to include; all methods if empty
+	Exclude      []string       // patterns of method names to exclude
+	KeepEmbedded bool           // whether to keep embedded interfaces instead of flattening them
+	Compact      []string       // packages which interfaces replace methods they consist of, e.g. DefaultCompact
+	Strict       bool           // whether to fail if method signatures or embedded types failed to type-check
+
+	// Warnf, if non-nil, is called to report problems that do not prevent
+	// building an interface, e.g. ambiguous methods of embedded fields.
+	Warnf func(format string, args ...interface{})
+
+	// Unmatched, if non-nil, is called with each of the Include and Exclude
+	// patterns, which does not match any method, instead of failing. It lets
+	// callers sharing patterns among many types report ones that match none.
+	Unmatched func(pattern string)
 
 	CSVHeader  []string
 	CSVRecord  []string
 	TimeFormat string
 }
 
-func (opts *Options) context() *build.Context {
-	if opts.Context != nil {
-		return opts.Context
+func (opts *Options) warnf(format string, args ...interface{}) {
+	if opts.Warnf != nil {
+		opts.Warnf(format, args...)
 	}
-	return &build.Default
 }
 
-func notFoundErr(opts *Options) error {
-	return fmt.Errorf("no exported methods found for %q (package %q)",
-		opts.Query.TypeName, opts.Query.Package)
+// env gives the environment for the go command, overriding the values
+// set in the build context, if any.
+func (opts *Options) env() []string {
+	if opts.Context == nil {
+		return opts.Env
+	}
+	env := opts.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	ctx := opts.Context
+	cgo := "0"
+	if ctx.CgoEnabled {
+		cgo = "1"
+	}
+	env = append(env[:len(env):len(env)],
+		"GOOS="+ctx.GOOS,
+		"GOARCH="+ctx.GOARCH,
+		"CGO_ENABLED="+cgo,
+	)
+	if ctx.GOPATH != "" {
+		env = append(env, "GOPATH="+ctx.GOPATH)
+	}
+	return env
+}
+
+// buildFlags gives the flags for the go command, including build tags
+// set in the build context, if any.
+func (opts *Options) buildFlags() []string {
+	if opts.Context == nil || len(opts.Context.BuildTags) == 0 {
+		return opts.BuildFlags
+	}
+	tags := "-tags=" + strings.Join(opts.Context.BuildTags, ",")
+	return append(opts.BuildFlags[:len(opts.BuildFlags):len(opts.BuildFlags)], tags)
 }
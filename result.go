@@ -0,0 +1,133 @@
+package interfaces
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// Result is an interface built for a type, together with metadata of the type
+// and of the interface methods, which links them back to the source.
+type Result struct {
+	Interface  Interface `json:"interface"`            // the interface built for the type
+	Package    string    `json:"package,omitempty"`    // name of the package of the type
+	ImportPath string    `json:"importPath,omitempty"` // import path of the package of the type
+	TypeName   string    `json:"typeName,omitempty"`   // name of the type
+	Kind       Kind      `json:"kind"`                 // kind of the underlying type; KindNamed for predeclared ones
+	Methods    []Method  `json:"methods,omitempty"`    // metadata of Interface.Methods, in the same order
+}
+
+// NewResult builds an interface definition for a type specified by the given
+// Options, like NewWithOptions does, together with metadata of the type and
+// its methods.
+func NewResult(opts *Options) (*Result, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("interfaces: called NewResult with nil Options")
+	}
+	if err := opts.Query.valid(); err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return buildResult(opts)
+}
+
+// Method holds metadata of an interface method.
+type Method struct {
+	Name     string         `json:"name"`             // name of the method
+	Receiver Receiver       `json:"receiver"`         // ReceiverPointer if declared on *T, ReceiverValue otherwise
+	Path     []Embedding    `json:"path,omitempty"`   // embedded types the method is promoted through, outermost first
+	Pos      token.Position `json:"pos,omitzero"`     // position of the method declaration
+	Recv     string         `json:"recv,omitempty"`   // type the method is declared on, e.g. *Bar
+	RecvPos  token.Position `json:"recvPos,omitzero"` // position of the declaration of the receiver type
+}
+
+// Embedding is an embedded type a method is promoted through.
+type Embedding struct {
+	Type string         `json:"type"`         // embedded type, e.g. *Bar or io.Reader
+	Pos  token.Position `json:"pos,omitzero"` // position of the embedded field, if any
+}
+
+// EmbeddingPath gives the path through which the method is promoted from
+// the type of the given name, e.g. "Baz -> *Bar". For methods declared on
+// the type, it is just the name.
+func (m Method) EmbeddingPath(typeName string) string {
+	path := []string{typeName}
+	for _, e := range m.Path {
+		path = append(path, e.Type)
+	}
+	return strings.Join(path, " -> ")
+}
+
+// newMethod gives metadata of the method selected from the named type.
+func newMethod(typ *types.Named, sel *types.Selection, fset *token.FileSet) Method {
+	method := sel.Obj().(*types.Func)
+	qualifier := types.RelativeTo(typ.Obj().Pkg())
+	m := Method{
+		Name:     method.Name(),
+		Receiver: ReceiverValue,
+		Pos:      fset.Position(method.Origin().Pos()),
+	}
+	if recv := method.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if p, ok := t.(*types.Pointer); ok {
+			m.Receiver = ReceiverPointer
+			t = p.Elem()
+		}
+		m.Recv = types.TypeString(recv.Type(), qualifier)
+		if named, ok := types.Unalias(t).(*types.Named); ok {
+			m.RecvPos = fset.Position(named.Obj().Pos())
+		}
+	}
+	// Embedded struct fields are found by the index of the selection.
+	var t types.Type = typ
+	index := sel.Index()
+	for _, i := range index[:len(index)-1] {
+		s, ok := deref(t).Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		f := s.Field(i)
+		m.Path = append(m.Path, Embedding{
+			Type: types.TypeString(f.Type(), qualifier),
+			Pos:  fset.Position(f.Pos()),
+		})
+		t = f.Type()
+	}
+	// Methods sets of interfaces are flattened, so interfaces the method
+	// comes from are looked up by the method object.
+	if iface, ok := deref(t).Underlying().(*types.Interface); ok {
+		m.Path = append(m.Path, interfacePath(iface, method, qualifier)...)
+	}
+	return m
+}
+
+// interfacePath gives interfaces embedded by iface, directly or not, which
+// the method comes from, outermost first.
+func interfacePath(iface *types.Interface, method *types.Func, qualifier types.Qualifier) []Embedding {
+	for i := 0; i < iface.NumExplicitMethods(); i++ {
+		if iface.ExplicitMethod(i) == method {
+			return nil
+		}
+	}
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		embedded := iface.EmbeddedType(i)
+		inner, ok := embedded.Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		for j := 0; j < inner.NumMethods(); j++ {
+			if inner.Method(j) == method {
+				path := []Embedding{{Type: types.TypeString(embedded, qualifier)}}
+				return append(path, interfacePath(inner, method, qualifier)...)
+			}
+		}
+	}
+	return nil
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
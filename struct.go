@@ -3,7 +3,6 @@ package interfaces
 import (
 	"bytes"
 	"errors"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,7 +17,7 @@ type Tag struct {
 
 // String
 func (t Tag) String() string {
-	return t.Name + `:"` + t.Value + `"`
+	return t.Name + ":" + strconv.Quote(t.Value)
 }
 
 // Tags
@@ -29,27 +28,58 @@ func (t Tags) String() string {
 	if len(t) == 0 {
 		return ""
 	}
-	var buf bytes.Buffer
-	buf.WriteString("`")
-	for _, tag := range t {
-		buf.WriteString(tag.String())
-		buf.WriteByte(' ')
-	}
-	p := buf.Bytes()
-	p[len(p)-1] = '`' // replace danling whitespace
-	return string(p)
+	return quoteTag(t.raw())
+}
+
+func (t Tags) raw() string {
+	s := make([]string, len(t))
+	for i, tag := range t {
+		s[i] = tag.String()
+	}
+	return strings.Join(s, " ")
 }
 
 // Field
 type Field struct {
 	Name string `json:"name,omitempty"`
 	Type Type   `json:"type,omitempty"`
-	Tags Tags   `json:"tags,omitempty"`
+	Tag  string `json:"tag,omitempty"`  // raw struct tag, as read from source
+	Tags Tags   `json:"tags,omitempty"` // tags added to the raw one
 }
 
 // String
 func (f *Field) String() string {
-	return f.Name + " " + f.Type.String() + " " + f.Tags.String()
+	return f.Name + " " + f.Type.String() + " " + f.tag()
+}
+
+// ParsedTags gives tags of the field, ones of the raw tag parsed in the
+// conventional format, e.g. `json:"name,omitempty" xml:"name"`, followed
+// by Tags. Malformed parts of the raw tag are ignored.
+func (f *Field) ParsedTags() Tags {
+	return append(parseTags(f.Tag), f.Tags...)
+}
+
+// tag gives Go code representation of the field tag, or an empty string if
+// the field has none. The raw tag is written verbatim, so the type of the
+// field's struct stays identical to the one it was read from.
+func (f *Field) tag() string {
+	switch {
+	case f.Tag == "":
+		return f.Tags.String()
+	case len(f.Tags) == 0:
+		return quoteTag(f.Tag)
+	default:
+		return quoteTag(f.Tag + " " + f.Tags.raw())
+	}
+}
+
+// quoteTag gives a Go string literal of the tag, a raw one unless the tag
+// contains a backtick.
+func quoteTag(tag string) string {
+	if strings.ContainsRune(tag, '`') {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
 }
 
 // Struct
@@ -57,20 +87,11 @@ type Struct []Field
 
 // Deps
 func (s Struct) Deps() []string {
-	pkgs := make(map[string]struct{}, 0)
+	pkgs := make(map[string]struct{})
 	for i := range s {
-		pkgs[s[i].Type.ImportPath] = struct{}{}
-	}
-	delete(pkgs, "")
-	if len(pkgs) == 0 {
-		return nil
-	}
-	deps := make([]string, 0, len(pkgs))
-	for pkg := range pkgs {
-		deps = append(deps, pkg)
+		s[i].Type.importPaths(pkgs)
 	}
-	sort.Strings(deps)
-	return deps
+	return sortedDeps(pkgs)
 }
 
 // String
@@ -102,7 +123,7 @@ func (s Struct) String() string {
 		for n := maxType - len(types[i]) + 1; n > 0; n-- {
 			buf.WriteByte(' ')
 		}
-		buf.WriteString(s[i].Tags.String())
+		buf.WriteString(s[i].tag())
 		buf.WriteByte('\n')
 	}
 	return buf.String()
@@ -184,3 +205,35 @@ func toFieldName(s string) string {
 	}
 	return string(r[:i])
 }
+
+// parseTags parses a struct tag in the conventional format. Malformed parts
+// of the tag are ignored.
+func parseTags(tag string) Tags {
+	var tags Tags
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		i := strings.Index(tag, `:"`)
+		if i <= 0 || strings.ContainsAny(tag[:i], " \"") {
+			break
+		}
+		name := tag[:i]
+		tag = tag[i+1:]
+		j := 1
+		for j < len(tag) && tag[j] != '"' {
+			if tag[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(tag) {
+			break
+		}
+		value, err := strconv.Unquote(tag[:j+1])
+		if err != nil {
+			break
+		}
+		tags = append(tags, Tag{Name: name, Value: value})
+		tag = tag[j+1:]
+	}
+	return tags
+}
@@ -0,0 +1,46 @@
+package fake
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLoggerFake(t *testing.T) {
+	var got string
+	f := &LoggerFake{
+		PrintfFunc: func(format string, v ...any) {
+			got = fmt.Sprintf(format, v...)
+		},
+		PrefixFunc: func() string {
+			return "prefix"
+		},
+	}
+
+	f.Printf("%d-%s", 1, "a")
+	if want := "1-a"; got != want {
+		t.Fatalf("want %q; got %q", want, got)
+	}
+	if got, want := f.Prefix(), "prefix"; got != want {
+		t.Fatalf("want %q; got %q", want, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("want panic for a method which func field is nil")
+		}
+	}()
+	f.Println()
+}
+
+func TestPointerFake(t *testing.T) {
+	x := 42
+	var p Pointer[int] = &PointerFake[int]{
+		LoadFunc: func() *int {
+			return &x
+		},
+	}
+
+	if got := p.Load(); got != &x {
+		t.Fatalf("want %p; got %p", &x, got)
+	}
+}
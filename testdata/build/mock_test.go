@@ -0,0 +1,61 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// recorder is a testing.TB, which records reported errors.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRoundTripperMock(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("NewRequest()=%s", err)
+	}
+	resp := &http.Response{StatusCode: http.StatusNoContent}
+
+	m := new(RoundTripperMock)
+	m.On("RoundTrip", req).Return(resp).Once()
+
+	r := &recorder{TB: t}
+	m.AssertExpectations(r)
+	if len(r.errs) != 1 {
+		t.Fatalf("want 1 error for a call which was not made; got %q", r.errs)
+	}
+
+	got, err := m.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip()=%s", err)
+	}
+	if got != resp {
+		t.Fatalf("want %v; got %v", resp, got)
+	}
+
+	r = &recorder{TB: t}
+	m.AssertExpectations(r)
+	if len(r.errs) != 0 {
+		t.Fatalf("want no errors; got %q", r.errs)
+	}
+
+	if calls := m.Calls(); len(calls) != 1 || calls[0].Method != "RoundTrip" {
+		t.Fatalf("want 1 call of RoundTrip; got %v", calls)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("want panic for an unexpected call")
+		}
+	}()
+	m.RoundTrip(req)
+}
@@ -0,0 +1,92 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+// buffer is a ReadWriteCloser, which fails to close.
+type buffer struct {
+	bytes.Buffer
+}
+
+func (*buffer) Close() error {
+	return errors.New("closed")
+}
+
+// mustPanic fails the test if fn does not panic.
+func mustPanic(t *testing.T, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic")
+		}
+	}()
+	fn()
+}
+
+func TestReadWriteCloserReplayer(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "calls.json")
+
+	r := NewReadWriteCloserRecorder(new(buffer))
+	if n, err := r.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("Write()=%d, %v", n, err)
+	}
+	if n, err := r.Read(make([]byte, 2)); n != 2 || err != nil {
+		t.Fatalf("Read()=%d, %v", n, err)
+	}
+	if err := r.Close(); err == nil {
+		t.Fatal("want Close() to fail")
+	}
+	if err := r.Save(name); err != nil {
+		t.Fatalf("Save()=%s", err)
+	}
+
+	s, err := OpenReadWriteCloserReplayer(name)
+	if err != nil {
+		t.Fatalf("OpenReadWriteCloserReplayer()=%s", err)
+	}
+	if n, err := s.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("Write()=%d, %v", n, err)
+	}
+	if n, err := s.Read(make([]byte, 2)); n != 2 || err != nil {
+		t.Fatalf("Read()=%d, %v", n, err)
+	}
+	if err := s.Close(); err == nil || err.Error() != "closed" {
+		t.Fatalf("want Close() to fail with \"closed\"; got %v", err)
+	}
+	if calls := s.Unreplayed(); len(calls) != 0 {
+		t.Fatalf("want all calls replayed; got %v", calls)
+	}
+
+	mustPanic(t, func() { s.Write([]byte("abc")) })
+	mustPanic(t, func() { s.Write([]byte("xyz")) })
+}
+
+func TestLoggerReplayerUnreplayable(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "calls.json")
+
+	r := NewLoggerRecorder(log.New(io.Discard, "", 0))
+	if w := r.Writer(); w != io.Discard {
+		t.Fatalf("want io.Discard; got %v", w)
+	}
+	r.SetPrefix("test: ")
+	if err := r.Save(name); err != nil {
+		t.Fatalf("Save()=%s", err)
+	}
+
+	s, err := OpenLoggerReplayer(name)
+	if err != nil {
+		t.Fatalf("OpenLoggerReplayer()=%s", err)
+	}
+	s.SetPrefix("test: ")
+	if calls := s.Unreplayed(); len(calls) != 0 {
+		t.Fatalf("want calls of Writer not recorded; got %v", calls)
+	}
+
+	mustPanic(t, func() { s.Writer() })
+}
@@ -0,0 +1,73 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// conn is a Conn, which pings fail the given number of times.
+type conn struct {
+	Conn
+	fails int
+	pings []context.Context
+}
+
+func (c *conn) PingContext(ctx context.Context) error {
+	c.pings = append(c.pings, ctx)
+	if len(c.pings) <= c.fails {
+		return errors.New("ping failed")
+	}
+	return nil
+}
+
+func TestConnRetrier(t *testing.T) {
+	var backoffs []int
+	policy := ConnRetryPolicy{
+		MaxAttempts: 3,
+		Backoff: func(attempt int) time.Duration {
+			backoffs = append(backoffs, attempt)
+			return time.Millisecond
+		},
+		Timeout: time.Minute,
+	}
+
+	c := &conn{fails: 2}
+	if err := NewConnRetrier(c, policy).PingContext(context.Background()); err != nil {
+		t.Fatalf("PingContext()=%s", err)
+	}
+	if len(c.pings) != 3 {
+		t.Fatalf("want 3 attempts; got %d", len(c.pings))
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(backoffs, want) {
+		t.Fatalf("want backoffs after attempts %v; got %v", want, backoffs)
+	}
+	for i, ctx := range c.pings[:2] {
+		if ctx.Err() == nil {
+			t.Errorf("want context of failed attempt %d canceled", i+1)
+		}
+	}
+	if err := c.pings[2].Err(); err != nil {
+		t.Errorf("want context of the successful attempt not canceled; got %s", err)
+	}
+
+	backoffs = nil
+	c = &conn{fails: 5}
+	if err := NewConnRetrier(c, policy).PingContext(context.Background()); err == nil {
+		t.Fatal("want PingContext() to fail")
+	}
+	if len(c.pings) != 3 || len(backoffs) != 2 {
+		t.Fatalf("want 3 attempts and 2 backoffs; got %d and %d", len(c.pings), len(backoffs))
+	}
+
+	policy.Retryable = func(error) bool { return false }
+	c = &conn{fails: 5}
+	if err := NewConnRetrier(c, policy).PingContext(context.Background()); err == nil {
+		t.Fatal("want PingContext() to fail")
+	}
+	if len(c.pings) != 1 {
+		t.Fatalf("want 1 attempt of a non-retryable error; got %d", len(c.pings))
+	}
+}
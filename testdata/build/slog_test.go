@@ -0,0 +1,44 @@
+package slog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// entry is a record written by slog.JSONHandler.
+type entry struct {
+	Method string         `json:"method"`
+	Args   map[string]any `json:"args"`
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) entry {
+	t.Helper()
+	var e entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("Unmarshal()=%s", err)
+	}
+	buf.Reset()
+	return e
+}
+
+func TestLevelVarLoggerRedact(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLevelVarLogger(new(slog.LevelVar), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	// The parameter l of Set clashes with the receiver of the decorator, yet
+	// it is logged and redacted under its declared name.
+	l.Set(slog.LevelWarn)
+	if e := decode(t, &buf); e.Method != "Set" || e.Args["l"] != "WARN" {
+		t.Fatalf("want Set logged with l=WARN; got %+v", e)
+	}
+
+	l.Redact("Set", "l").Set(slog.LevelWarn)
+	if bytes.Contains(buf.Bytes(), []byte("WARN")) {
+		t.Fatalf("want no WARN in the output; got %s", &buf)
+	}
+	if e := decode(t, &buf); e.Args["l"] != "[REDACTED]" {
+		t.Fatalf("want l redacted; got %+v", e)
+	}
+}
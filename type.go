@@ -1,151 +1,498 @@
 package interfaces
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"go/types"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// Type is a simple representation of a single parameter type.
+// Kind describes what kind of type a Type represents.
+type Kind uint8
+
+// Kinds of types.
+const (
+	KindNamed     Kind = iota // named or predeclared type, or type parameter, e.g. int, os.File or T
+	KindPointer               // pointer, e.g. *T
+	KindSlice                 // slice, e.g. []T
+	KindArray                 // array, e.g. [3]T
+	KindMap                   // map, e.g. map[K]V
+	KindChan                  // channel, e.g. chan T or <-chan T
+	KindFunc                  // function literal, e.g. func(int) error
+	KindStruct                // struct literal, e.g. struct{}
+	KindInterface             // interface literal, e.g. interface{ Close() error }
+	KindUnion                 // union of type terms, e.g. ~int | ~string
+)
+
+var kinds = [...]string{
+	KindNamed:     "named",
+	KindPointer:   "pointer",
+	KindSlice:     "slice",
+	KindArray:     "array",
+	KindMap:       "map",
+	KindChan:      "chan",
+	KindFunc:      "func",
+	KindStruct:    "struct",
+	KindInterface: "interface",
+	KindUnion:     "union",
+}
+
+// String gives a text representation of the kind.
+func (k Kind) String() string {
+	if int(k) < len(kinds) {
+		return kinds[k]
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k Kind) MarshalText() ([]byte, error) {
+	if int(k) >= len(kinds) {
+		return nil, errors.New("invalid kind: " + k.String())
+	}
+	return []byte(kinds[k]), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *Kind) UnmarshalText(p []byte) error {
+	for i, s := range kinds {
+		if s == string(p) {
+			*k = Kind(i)
+			return nil
+		}
+	}
+	return errors.New("invalid kind: " + string(p))
+}
+
+// ChanDir describes a direction of a channel type.
+type ChanDir uint8
+
+// Directions of channel types.
+const (
+	SendRecv ChanDir = iota // chan T
+	SendOnly                // chan<- T
+	RecvOnly                // <-chan T
+)
+
+// Type is a representation of a single parameter type. Types other than
+// named ones are represented as a tree, e.g. map[*a.K][]*b.V is a map, which
+// key is a pointer to a named type a.K and which value is a slice of pointers
+// to a named type b.V.
 type Type struct {
-	Name        string `json:"name,omitempty"`        // type name
-	Package     string `json:"package,omitempty"`     // package name the type is defined in; empty for builtin
-	ImportPath  string `json:"importPath,omitempty"`  // import path of the package
-	IsPointer   bool   `json:"isPointer,omitempty"`   // whether the parameter is a pointer
-	IsComposite bool   `json:"isComposite,omitempty"` // whether the type is map, slice, chan or array
-	IsFunc      bool   `json:"isFunc,omitempty"`      // whether the type if function
+	Name       string  `json:"name,omitempty"`       // type name; empty for types other than named
+	Package    string  `json:"package,omitempty"`    // package name the type is defined in; empty for builtin
+	ImportPath string  `json:"importPath,omitempty"` // import path of the package
+	Kind       Kind    `json:"kind,omitempty"`       // kind of the type
+	Underlying Kind    `json:"underlying,omitempty"` // kind of the underlying type of a named type, e.g. interface for io.Reader
+	Elem       *Type   `json:"elem,omitempty"`       // element type of a pointer, slice, array, map or chan
+	Key        *Type   `json:"key,omitempty"`        // key type of a map
+	Len        int64   `json:"len,omitempty"`        // length of an array
+	Dir        ChanDir `json:"dir,omitempty"`        // direction of a chan
+	TypeArgs   []Type  `json:"typeArgs,omitempty"`   // type arguments of an instantiated named type
+	Func       *Func   `json:"func,omitempty"`       // signature of a func literal
+	Fields     []Field `json:"fields,omitempty"`     // fields of a struct literal; embedded ones are unnamed
+	Methods    []Func  `json:"methods,omitempty"`    // methods of an interface literal
+	Embeds     []Type  `json:"embeds,omitempty"`     // embedded elements of an interface literal
+	Terms      []Type  `json:"terms,omitempty"`      // terms of a union
+	Tilde      bool    `json:"tilde,omitempty"`      // whether the type is a ~T term of a union
 }
 
 // String gives Go code representation of the type.
-func (typ Type) String() (s string) {
-	if typ.IsPointer {
-		s = "*"
+func (typ Type) String() string {
+	var buf bytes.Buffer
+	typ.write(&buf)
+	return buf.String()
+}
+
+func (typ *Type) write(buf *bytes.Buffer) {
+	if typ.Tilde {
+		buf.WriteByte('~')
 	}
-	if !typ.IsComposite && typ.Package != "" {
-		s = s + typ.Package + "."
+	switch typ.Kind {
+	case KindPointer:
+		buf.WriteByte('*')
+		typ.Elem.write(buf)
+	case KindSlice:
+		buf.WriteString("[]")
+		typ.Elem.write(buf)
+	case KindArray:
+		fmt.Fprintf(buf, "[%d]", typ.Len)
+		typ.Elem.write(buf)
+	case KindMap:
+		buf.WriteString("map[")
+		typ.Key.write(buf)
+		buf.WriteByte(']')
+		typ.Elem.write(buf)
+	case KindChan:
+		switch typ.Dir {
+		case SendOnly:
+			buf.WriteString("chan<- ")
+		case RecvOnly:
+			buf.WriteString("<-chan ")
+		default:
+			buf.WriteString("chan ")
+		}
+		// A chan of a receive-only chan must be parenthesized,
+		// as "chan <-chan T" is parsed as "chan<- chan T".
+		paren := typ.Dir == SendRecv && typ.Elem.Kind == KindChan && typ.Elem.Dir == RecvOnly
+		if paren {
+			buf.WriteByte('(')
+		}
+		typ.Elem.write(buf)
+		if paren {
+			buf.WriteByte(')')
+		}
+	case KindFunc:
+		buf.WriteString("func")
+		buf.WriteString(typ.Func.String())
+	case KindStruct:
+		buf.WriteString("struct{")
+		for i, f := range typ.Fields {
+			if i != 0 {
+				buf.WriteString("; ")
+			}
+			if f.Name != "" {
+				buf.WriteString(f.Name)
+				buf.WriteByte(' ')
+			}
+			f.Type.write(buf)
+			if tag := f.tag(); tag != "" {
+				buf.WriteByte(' ')
+				buf.WriteString(tag)
+			}
+		}
+		buf.WriteByte('}')
+	case KindInterface:
+		if len(typ.Embeds) == 0 && len(typ.Methods) == 0 {
+			buf.WriteString("interface{}")
+			return
+		}
+		buf.WriteString("interface{ ")
+		for i, e := range typ.Embeds {
+			if i != 0 {
+				buf.WriteString("; ")
+			}
+			e.write(buf)
+		}
+		for i, m := range typ.Methods {
+			if i != 0 || len(typ.Embeds) != 0 {
+				buf.WriteString("; ")
+			}
+			buf.WriteString(m.String())
+		}
+		buf.WriteString(" }")
+	case KindUnion:
+		for i, t := range typ.Terms {
+			if i != 0 {
+				buf.WriteString(" | ")
+			}
+			t.write(buf)
+		}
+	default:
+		if typ.Package != "" {
+			buf.WriteString(typ.Package)
+			buf.WriteByte('.')
+		}
+		buf.WriteString(typ.Name)
+		if len(typ.TypeArgs) != 0 {
+			buf.WriteByte('[')
+			for i, t := range typ.TypeArgs {
+				if i != 0 {
+					buf.WriteString(", ")
+				}
+				t.write(buf)
+			}
+			buf.WriteByte(']')
+		}
 	}
-	return s + typ.Name
 }
 
-func newType(v *types.Var) (typ Type) {
-	typ.setFromType(v.Type(), 0, nil)
-	return typ
+// Deps gives a list of packages the type depends on. E.g. if the type
+// represents map[net.Flags]func(http.Handler) *template.Template, calling Deps()
+// will return []string{"html/template", "net", "net/http"}.
+//
+// The packages are sorted by import path.
+func (typ Type) Deps() []string {
+	pkgs := make(map[string]struct{})
+	typ.importPaths(pkgs)
+	return sortedDeps(pkgs)
+}
+
+// importPaths adds to pkgs import paths of all the named types
+// the type is composed of.
+func (typ *Type) importPaths(pkgs map[string]struct{}) {
+	typ.walk(func(typ *Type) {
+		if typ.ImportPath != "" {
+			pkgs[typ.ImportPath] = struct{}{}
+		}
+	})
 }
 
-type compositeType interface {
-	types.Type
-	Elem() types.Type
+// walk calls fn for the type and for all the types it is composed of.
+func (typ *Type) walk(fn func(*Type)) {
+	fn(typ)
+	if typ.Elem != nil {
+		typ.Elem.walk(fn)
+	}
+	if typ.Key != nil {
+		typ.Key.walk(fn)
+	}
+	if typ.Func != nil {
+		typ.Func.walk(fn)
+	}
+	for _, list := range [][]Type{typ.TypeArgs, typ.Embeds, typ.Terms} {
+		for i := range list {
+			list[i].walk(fn)
+		}
+	}
+	for i := range typ.Fields {
+		typ.Fields[i].Type.walk(fn)
+	}
+	for i := range typ.Methods {
+		typ.Methods[i].walk(fn)
+	}
 }
 
-func (typ *Type) setFromType(t types.Type, depth int, orig types.Type) {
-	if orig == nil {
-		orig = t
+// sortedDeps gives sorted import paths of the given packages or nil,
+// if there are none.
+func sortedDeps(pkgs map[string]struct{}) []string {
+	if len(pkgs) == 0 {
+		return nil
 	}
-	if depth > 128 {
-		panic("recursive types not supported: " + orig.String())
+	deps := make([]string, 0, len(pkgs))
+	for pkg := range pkgs {
+		deps = append(deps, pkg)
 	}
+	sort.Strings(deps)
+	return deps
+}
+
+// TypeParam represents a type parameter of a generic interface.
+type TypeParam struct {
+	Name       string `json:"name,omitempty"`       // name of the type parameter
+	Constraint Type   `json:"constraint,omitempty"` // type constraint, e.g. any or comparable
+}
+
+// String gives Go code representation of the type parameter.
+func (tp TypeParam) String() string {
+	return tp.Name + " " + tp.Constraint.String()
+}
+
+func newTypeParam(t *types.TypeParam) (tp TypeParam, err error) {
+	tp.Name = t.Obj().Name()
+	err = tp.Constraint.setFromType(t.Constraint(), make(map[types.Type]bool))
+	return tp, err
+}
+
+// TypeParams represents a type parameter list of a generic interface.
+type TypeParams []TypeParam
+
+// String gives Go code representation of the type parameter list, as used
+// in a type declaration, e.g. [K comparable, V any]. It gives an empty string
+// if the list is empty.
+func (t TypeParams) String() string {
+	if len(t) == 0 {
+		return ""
+	}
+	s := make([]string, len(t))
+	for i, tp := range t {
+		s[i] = tp.String()
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+// Names gives Go code representation of the type parameter list, as used
+// in an instantiation of a generic type, e.g. [K, V]. It gives an empty string
+// if the list is empty.
+func (t TypeParams) Names() string {
+	if len(t) == 0 {
+		return ""
+	}
+	s := make([]string, len(t))
+	for i, tp := range t {
+		s[i] = tp.Name
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+// setFromType translates t into typ. The seen map holds types which are
+// being translated, in order to detect a type that refers to itself.
+// Named types are not expanded, so self-referential named types like
+// linked list nodes terminate the recursion on their own.
+func (typ *Type) setFromType(t types.Type, seen map[types.Type]bool) error {
+	if seen[t] {
+		return &ErrUnsupportedType{Type: t, Reason: "recursive type"}
+	}
+	seen[t] = true
+	defer delete(seen, t)
 	switch t := t.(type) {
 	case *types.Basic:
-		typ.setFromBasic(t)
-	case *types.Interface:
-		typ.setFromInterface(t)
-	case *types.Struct:
-		typ.setFromStruct(t)
+		typ.Name = t.Name()
 	case *types.Named:
-		typ.setFromNamed(t)
-	case *types.Signature:
-		typ.IsFunc = true
-		typ.setFromSignature(t)
+		typ.setFromObj(t.Obj())
+		typ.Underlying = kindOf(t.Underlying())
+		return typ.setTypeArgs(t.TypeArgs(), seen)
+	case *types.Alias:
+		typ.setFromObj(t.Obj())
+		typ.Underlying = kindOf(t.Underlying())
+		return typ.setTypeArgs(t.TypeArgs(), seen)
+	case *types.TypeParam:
+		typ.Name = t.Obj().Name()
 	case *types.Pointer:
-		if depth == 0 {
-			typ.IsPointer = true
-		}
-		typ.setFromType(t.Elem(), depth+1, orig)
+		typ.Kind = KindPointer
+		return typ.setElem(t.Elem(), seen)
+	case *types.Slice:
+		typ.Kind = KindSlice
+		return typ.setElem(t.Elem(), seen)
+	case *types.Array:
+		typ.Kind = KindArray
+		typ.Len = t.Len()
+		return typ.setElem(t.Elem(), seen)
 	case *types.Map:
-		typ.setFromComposite(t, depth, orig)
-		typ.setFromType(t.Key(), depth+1, orig)
-	case *types.Alias:
-		typ.setFromType(t.Underlying(), depth+1, orig)
-	case compositeType:
-		typ.setFromComposite(t, depth, orig)
+		typ.Kind = KindMap
+		typ.Key = new(Type)
+		if err := typ.Key.setFromType(t.Key(), seen); err != nil {
+			return err
+		}
+		return typ.setElem(t.Elem(), seen)
+	case *types.Chan:
+		typ.Kind = KindChan
+		typ.Dir = chanDirs[t.Dir()]
+		return typ.setElem(t.Elem(), seen)
+	case *types.Signature:
+		typ.Kind = KindFunc
+		fn, err := newFunc("", t, seen)
+		if err != nil {
+			return err
+		}
+		typ.Func = &fn
+	case *types.Struct:
+		return typ.setFromStruct(t, seen)
+	case *types.Interface:
+		return typ.setFromInterface(t, seen)
+	case *types.Union:
+		return typ.setFromUnion(t, seen)
 	default:
-		panic(fmt.Sprintf("internal: t=%T, orig=%T", t, orig))
+		return &ErrUnsupportedType{Type: t}
 	}
+	return nil
 }
 
-func (typ *Type) setFromBasic(t *types.Basic) {
-	if typ.Name == "" {
-		typ.Name = t.Name()
-	}
+var chanDirs = map[types.ChanDir]ChanDir{
+	types.SendRecv: SendRecv,
+	types.SendOnly: SendOnly,
+	types.RecvOnly: RecvOnly,
 }
 
-func (typ *Type) setFromInterface(t *types.Interface) {
-	if typ.Name == "" {
-		typ.Name = t.String()
+// kindOf gives the kind of a type literal; KindNamed for anything else.
+func kindOf(t types.Type) Kind {
+	switch t.(type) {
+	case *types.Pointer:
+		return KindPointer
+	case *types.Slice:
+		return KindSlice
+	case *types.Array:
+		return KindArray
+	case *types.Map:
+		return KindMap
+	case *types.Chan:
+		return KindChan
+	case *types.Signature:
+		return KindFunc
+	case *types.Struct:
+		return KindStruct
+	case *types.Interface:
+		return KindInterface
 	}
+	return KindNamed
 }
 
-func (typ *Type) setFromStruct(t *types.Struct) {
-	if typ.Name == "" {
-		typ.Name = t.String()
-	}
+func (typ *Type) setElem(t types.Type, seen map[types.Type]bool) error {
+	typ.Elem = new(Type)
+	return typ.Elem.setFromType(t, seen)
 }
 
-func (typ *Type) setFromSignature(t *types.Signature) {
-	if typ.Name == "" {
-		typ.Name = t.String()
+func (typ *Type) setTypeArgs(targs *types.TypeList, seen map[types.Type]bool) error {
+	if targs.Len() == 0 {
+		return nil
+	}
+	typ.TypeArgs = make([]Type, targs.Len())
+	for i := range typ.TypeArgs {
+		if err := typ.TypeArgs[i].setFromType(targs.At(i), seen); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (typ *Type) setFromNamed(t *types.Named) {
-	if typ.Name == "" {
-		typ.Name = t.Obj().Name()
-	}
-	if typ.Package != "" || typ.ImportPath != "" {
-		return
-	}
-	if pkg := t.Obj().Pkg(); pkg != nil {
+func (typ *Type) setFromObj(obj *types.TypeName) {
+	typ.Name = obj.Name()
+	if pkg := obj.Pkg(); pkg != nil {
 		typ.Package = pkg.Name()
-		typ.ImportPath = pkg.Path()
+		typ.ImportPath = trimVendorPath(pkg.Path())
 	}
 }
 
-func (typ *Type) setFromComposite(t compositeType, depth int, orig types.Type) {
-	typ.IsComposite = true
-	if typ.Name == "" {
-		typ.Name = t.String()
+func (typ *Type) setFromStruct(t *types.Struct, seen map[types.Type]bool) error {
+	typ.Kind = KindStruct
+	if t.NumFields() == 0 {
+		return nil
+	}
+	typ.Fields = make([]Field, t.NumFields())
+	for i := range typ.Fields {
+		v := t.Field(i)
+		f := &typ.Fields[i]
+		if !v.Embedded() {
+			f.Name = v.Name()
+		}
+		f.Tag = t.Tag(i)
+		if err := f.Type.setFromType(v.Type(), seen); err != nil {
+			return err
+		}
 	}
-	typ.setFromType(t.Elem(), depth+1, orig)
+	return nil
 }
 
-func fixup(typ *Type, q *Query) {
-	// Hacky fixup for renaming:
-	//
-	//   GeoAdd(string, []*github.com/go-redis/redis.GeoLocation) *redis.IntCmd
-	//
-	// to:
-	//
-	//   GeoAdd(string, []*redis.GeoLocation) *redis.IntCmd
-	//
-	// Should be fixed layer below, in type.go.
-
-	// when include other package struct
-	if typ.ImportPath != "" && typ.IsComposite {
-		if typ.ImportPath == q.Package {
-			typ.Name = strings.Replace(typ.Name, typ.ImportPath, typ.Package, -1)
+func (typ *Type) setFromInterface(t *types.Interface, seen map[types.Type]bool) error {
+	// An implicit interface is a constraint written without the interface
+	// keyword, e.g. ~int | ~string; it is translated to its only element.
+	if t.IsImplicit() && t.NumEmbeddeds() == 1 && t.NumExplicitMethods() == 0 {
+		return typ.setFromType(t.EmbeddedType(0), seen)
+	}
+	typ.Kind = KindInterface
+	for i := 0; i < t.NumEmbeddeds(); i++ {
+		var e Type
+		if err := e.setFromType(t.EmbeddedType(i), seen); err != nil {
+			return err
 		}
-
-		if typ.ImportPath != q.Package {
-			pkgIdx := strings.LastIndex(typ.ImportPath, typ.Package)
-			if 0 < pkgIdx {
-				typ.Name = strings.Replace(typ.Name, typ.ImportPath[:pkgIdx], "", -1)
-			}
+		typ.Embeds = append(typ.Embeds, e)
+	}
+	for i := 0; i < t.NumExplicitMethods(); i++ {
+		m := t.ExplicitMethod(i)
+		fn, err := newFunc(m.Name(), m.Type().(*types.Signature), seen)
+		if err != nil {
+			return err
 		}
+		typ.Methods = append(typ.Methods, fn)
 	}
+	return nil
+}
 
-	typ.Name = strings.Replace(typ.Name, q.Package, path.Base(q.Package), -1)
-	typ.ImportPath = trimVendorPath(typ.ImportPath)
+func (typ *Type) setFromUnion(t *types.Union, seen map[types.Type]bool) error {
+	typ.Kind = KindUnion
+	typ.Terms = make([]Type, t.Len())
+	for i := range typ.Terms {
+		term := t.Term(i)
+		if err := typ.Terms[i].setFromType(term.Type(), seen); err != nil {
+			return err
+		}
+		typ.Terms[i].Tilde = term.Tilde()
+	}
+	return nil
 }
 
 // trimVendorPath removes the vendor dir prefix from a package path.
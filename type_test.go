@@ -1,232 +1,312 @@
 package interfaces
 
-import "testing"
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
 
-func Test_fixup(t *testing.T) {
+func named(pkg, name string) *Type {
+	return &Type{
+		Name:       name,
+		Package:    pkg,
+		ImportPath: "example.com/" + pkg,
+	}
+}
+
+func TestTypeString(t *testing.T) {
 	cases := map[string]struct {
-		typ   *Type
-		q     *Query
-		fixed string
+		typ  *Type
+		deps []string
 	}{
-		"func (s *Sample) String() string": {
-			typ: &Type{
-				Name:        "string",
-				Package:     "",
-				ImportPath:  "",
-				IsPointer:   false,
-				IsComposite: false,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "string",
+		"string": {
+			typ: &Type{Name: "string"},
 		},
-		"func (s *Sample) Strings() []string": {
+		"**a.T": {
 			typ: &Type{
-				Name:        "[]string",
-				Package:     "",
-				ImportPath:  "",
-				IsPointer:   false,
-				IsComposite: true,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
+				Kind: KindPointer,
+				Elem: &Type{Kind: KindPointer, Elem: named("a", "T")},
 			},
-			fixed: "[]string",
+			deps: []string{"example.com/a"},
 		},
-		"func (s *Sample) Bar() *Bar": {
+		"*[]*a.T": {
 			typ: &Type{
-				Name:        "Bar",
-				Package:     "sample",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/sample",
-				IsPointer:   true,
-				IsComposite: false,
-				IsFunc:      false,
+				Kind: KindPointer,
+				Elem: &Type{
+					Kind: KindSlice,
+					Elem: &Type{Kind: KindPointer, Elem: named("a", "T")},
+				},
 			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "Bar",
+			deps: []string{"example.com/a"},
 		},
-		"func (s *Sample) Bars() []*Bar": {
+		"map[*a.K][]*b.V": {
 			typ: &Type{
-				Name:        "[]*github.com/rjeczalik/interfaces/test/sample.Bar",
-				Package:     "sample",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/sample",
-				IsPointer:   false,
-				IsComposite: true,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
+				Kind: KindMap,
+				Key:  &Type{Kind: KindPointer, Elem: named("a", "K")},
+				Elem: &Type{
+					Kind: KindSlice,
+					Elem: &Type{Kind: KindPointer, Elem: named("b", "V")},
+				},
 			},
-			fixed: "[]*sample.Bar",
+			deps: []string{"example.com/a", "example.com/b"},
 		},
-		"func (s *Sample) WithBar(b *Bar) error": {
-			typ: &Type{
-				Name:        "Bar",
-				Package:     "sample",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/sample",
-				IsPointer:   true,
-				IsComposite: false,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "Bar",
-		},
-		"func (s *Sample) WithBars(b []*Bar) error": {
-			typ: &Type{
-				Name:        "[]*github.com/rjeczalik/interfaces/test/sample.Bar",
-				Package:     "sample",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/sample",
-				IsPointer:   false,
-				IsComposite: true,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "[]*sample.Bar",
+		"[3]string": {
+			typ: &Type{Kind: KindArray, Len: 3, Elem: &Type{Name: "string"}},
 		},
-		"func (s *Sample) Hoge() *hoge.Hoge": {
-			typ: &Type{
-				Name:        "Hoge",
-				Package:     "hoge",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/hoge",
-				IsPointer:   true,
-				IsComposite: false,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "Hoge",
+		"chan<- int": {
+			typ: &Type{Kind: KindChan, Dir: SendOnly, Elem: &Type{Name: "int"}},
 		},
-		"func (s *Sample) Hoges() []*hoge.Hoge": {
+		"chan (<-chan int)": {
 			typ: &Type{
-				Name:        "[]*github.com/rjeczalik/interfaces/test/hoge.Hoge",
-				Package:     "hoge",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/hoge",
-				IsPointer:   false,
-				IsComposite: true,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
+				Kind: KindChan,
+				Elem: &Type{Kind: KindChan, Dir: RecvOnly, Elem: &Type{Name: "int"}},
 			},
-			fixed: "[]*hoge.Hoge",
 		},
-		"func (s *Sample) WithHoges(h []*hoge.Hoge) error": {
+		"func(int, ...string) error": {
 			typ: &Type{
-				Name:        "[]*github.com/rjeczalik/interfaces/test/hoge.Hoge",
-				Package:     "hoge",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/hoge",
-				IsPointer:   false,
-				IsComposite: true,
-				IsFunc:      false,
+				Kind: KindFunc,
+				Func: &Func{
+					Ins: []Type{
+						{Name: "int"},
+						{Kind: KindSlice, Elem: &Type{Name: "string"}},
+					},
+					Outs:       []Type{{Name: "error"}},
+					IsVariadic: true,
+				},
 			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "[]*hoge.Hoge",
 		},
-		"func (s *Sample) Func() func(x int) error": {
+		"a.Pair[string, *b.V]": {
 			typ: &Type{
-				Name:        "Func(x int) error",
-				Package:     "",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/sample",
-				IsPointer:   false,
-				IsComposite: false,
-				IsFunc:      true,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
+				Name:       "Pair",
+				Package:    "a",
+				ImportPath: "example.com/a",
+				TypeArgs: []Type{
+					{Name: "string"},
+					{Kind: KindPointer, Elem: named("b", "V")},
+				},
 			},
-			fixed: "Func(x int) error",
+			deps: []string{"example.com/a", "example.com/b"},
 		},
-		"func (s *Sample) WithFunc(func(x int)) error": {
+		"func(b.Handler) *a.Template": {
 			typ: &Type{
-				Name:        "WithFunc(func(x int)) error",
-				Package:     "",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/sample",
-				IsPointer:   false,
-				IsComposite: false,
-				IsFunc:      true,
+				Kind: KindFunc,
+				Func: &Func{
+					Ins:  []Type{*named("b", "Handler")},
+					Outs: []Type{{Kind: KindPointer, Elem: named("a", "Template")}},
+				},
 			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "WithFunc(func(x int)) error",
+			deps: []string{"example.com/a", "example.com/b"},
 		},
-		"func (s *Sample) WithMap(*[]map[*flag.FlagSet]struct{}, [3]string)": {
+		"struct{a.T; B b.T `json:\"b\"`}": {
 			typ: &Type{
-				Name:        "WithMap(*[]map[*flag.FlagSet]struct{}, [3]string)",
-				Package:     "flag",
-				ImportPath:  "flag",
-				IsPointer:   true,
-				IsComposite: true,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
+				Kind: KindStruct,
+				Fields: []Field{
+					{Type: *named("a", "T")},
+					{Name: "B", Type: *named("b", "T"), Tags: Tags{{Name: "json", Value: "b"}}},
+				},
 			},
-			fixed: "WithMap(*[]map[*flag.FlagSet]struct{}, [3]string)",
+			deps: []string{"example.com/a", "example.com/b"},
 		},
-		"func (s *Sample) WithMap2(*[]map[string]*hoge.Hoge, [3]string)": {
+		"interface{ a.Reader; Close() b.Error }": {
 			typ: &Type{
-				Name:        "WithMap2(*[]map[string]*github.com/rjeczalik/interfaces/test/hoge.Hoge)",
-				Package:     "hoge",
-				ImportPath:  "github.com/rjeczalik/interfaces/test/hoge",
-				IsPointer:   true,
-				IsComposite: true,
-				IsFunc:      false,
+				Kind:   KindInterface,
+				Embeds: []Type{*named("a", "Reader")},
+				Methods: []Func{{
+					Name: "Close",
+					Outs: []Type{*named("b", "Error")},
+				}},
 			},
-			q: &Query{
-				TypeName: "Sample",
-				Package:  "github.com/rjeczalik/interfaces/test/sample",
-			},
-			fixed: "WithMap2(*[]map[string]*hoge.Hoge)",
+			deps: []string{"example.com/a", "example.com/b"},
 		},
-		"func (s *Sample) ListBuckets() ([]minio.BucketInfo)": {
+		"~int | ~string": {
 			typ: &Type{
-				Name:        "[]github.com/minio/minio-go/v6.BucketInfo",
-				Package:     "minio",
-				ImportPath:  "github.com/minio/minio-go/v6",
-				IsPointer:   false,
-				IsComposite: true,
-				IsFunc:      false,
-			},
-			q: &Query{
-				TypeName: "Client",
-				Package:  "github.com/minio/minio-go/v6",
+				Kind: KindUnion,
+				Terms: []Type{
+					{Name: "int", Tilde: true},
+					{Name: "string", Tilde: true},
+				},
 			},
-			fixed: "[]minio.BucketInfo",
 		},
 	}
+	for want, cas := range cases {
+		t.Run(want, func(t *testing.T) {
+			if got := cas.typ.String(); got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+			if deps := cas.typ.Deps(); !reflect.DeepEqual(deps, cas.deps) {
+				t.Errorf("got deps %v; want %v", deps, cas.deps)
+			}
+		})
+	}
+}
+
+const typesSrc = `package a
+
+type K int
+
+type V struct{}
 
-	for k, c := range cases {
-		t.Run(k, func(t *testing.T) {
-			fixup(c.typ, c.q)
+type Node struct {
+	Next *Node
+}
+
+type Visitor func(Visitor) Visitor
+
+var (
+	_ **K
+	_ *[]*V
+	_ map[*K][]*V
+	_ [4][]map[string]chan<- *V
+	_ chan (<-chan K)
+	_ func(Visitor, ...*Node) (Visitor, error)
+	_ struct{ K; N *Node ` + "`json:\"n\"`" + ` }
+	_ interface{ Close() error }
+)
+`
 
-			if c.typ.Name != c.fixed {
-				t.Errorf("invalid fixedup result. got:%s want:%s", c.typ.Name, c.fixed)
+func TestTypeSetFromType(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", typesSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile()=%s", err)
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	if _, err := new(types.Config).Check("example.com/a", fset, []*ast.File{f}, info); err != nil {
+		t.Fatalf("Check()=%s", err)
+	}
+	want := []string{
+		"**a.K",
+		"*[]*a.V",
+		"map[*a.K][]*a.V",
+		"[4][]map[string]chan<- *a.V",
+		"chan (<-chan a.K)",
+		"func(a.Visitor, ...*a.Node) (a.Visitor, error)",
+		"struct{a.K; N *a.Node `json:\"n\"`}",
+		"interface{ Close() error }",
+	}
+	var got []string
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			var typ Type
+			if err := typ.setFromType(info.TypeOf(spec.(*ast.ValueSpec).Type), make(map[types.Type]bool)); err != nil {
+				t.Fatalf("setFromType()=%s", err)
 			}
-		})
+			got = append(got, typ.String())
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestTypeSetFromTypeUnderlying(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", typesSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile()=%s", err)
+	}
+	pkg, err := new(types.Config).Check("example.com/a", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatalf("Check()=%s", err)
+	}
+	cases := map[string]Kind{
+		"K":       KindNamed,
+		"V":       KindStruct,
+		"Node":    KindStruct,
+		"Visitor": KindFunc,
+	}
+	for name, want := range cases {
+		var typ Type
+		if err := typ.setFromType(pkg.Scope().Lookup(name).Type(), make(map[types.Type]bool)); err != nil {
+			t.Fatalf("setFromType()=%s", err)
+		}
+		if typ.Kind != KindNamed || typ.Underlying != want {
+			t.Errorf("%s: got %s (underlying %s); want named (underlying %s)", name, typ.Kind, typ.Underlying, want)
+		}
+	}
+	var typ Type
+	if err := typ.setFromType(types.Universe.Lookup("error").Type(), make(map[types.Type]bool)); err != nil {
+		t.Fatalf("setFromType()=%s", err)
+	}
+	if typ.Underlying != KindInterface {
+		t.Errorf("error: got underlying %s; want interface", typ.Underlying)
+	}
+}
+
+func TestTypeStringStructTag(t *testing.T) {
+	const src = "package a\n\nvar _ struct {\n\tA int `foo`\n\tB int `json:\"a\\\"b\"`\n\tC int `json:\"c\" xml:\"c\"`\n\tD int \"d:\\\"`\\\"\"\n}\n"
+	check := func(src string) types.Type {
+		t.Helper()
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "a.go", src, 0)
+		if err != nil {
+			t.Fatalf("ParseFile()=%s", err)
+		}
+		info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		if _, err := new(types.Config).Check("example.com/a", fset, []*ast.File{f}, info); err != nil {
+			t.Fatalf("Check()=%s", err)
+		}
+		return info.TypeOf(f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type)
+	}
+	orig := check(src)
+	var typ Type
+	if err := typ.setFromType(orig, make(map[types.Type]bool)); err != nil {
+		t.Fatalf("setFromType()=%s", err)
+	}
+	want := "struct{A int `foo`; B int `json:\"a\\\"b\"`; C int `json:\"c\" xml:\"c\"`; D int \"d:\\\"`\\\"\"}"
+	if got := typ.String(); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if got := check("package a\n\nvar _ " + typ.String() + "\n"); !types.Identical(got, orig) {
+		t.Errorf("got %s; want %s", got, orig)
+	}
+	tags := []Tags{
+		nil,
+		{{Name: "json", Value: `a"b`}},
+		{{Name: "json", Value: "c"}, {Name: "xml", Value: "c"}},
+		{{Name: "d", Value: "`"}},
+	}
+	for i, f := range typ.Fields {
+		if got := f.ParsedTags(); !reflect.DeepEqual(got, tags[i]) {
+			t.Errorf("%s: got %+v; want %+v", f.Name, got, tags[i])
+		}
+	}
+}
+
+func TestTrimVendorPath(t *testing.T) {
+	cases := map[string]string{
+		"github.com/pkg/errors":                           "github.com/pkg/errors",
+		"github.com/foo/bar/vendor/github.com/pkg/errors": "github.com/pkg/errors",
+	}
+	for p, want := range cases {
+		if got := trimVendorPath(p); got != want {
+			t.Errorf("trimVendorPath(%q)=%q; want %q", p, got, want)
+		}
+	}
+}
+
+// unknownType is a type go/types does not define.
+type unknownType struct{}
+
+func (unknownType) Underlying() types.Type { return unknownType{} }
+func (unknownType) String() string         { return "unknown" }
+
+func TestTypeSetFromTypeError(t *testing.T) {
+	var typ Type
+	err := typ.setFromType(types.NewSlice(unknownType{}), make(map[types.Type]bool))
+	var unsupported *ErrUnsupportedType
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("got %v; want *ErrUnsupportedType", err)
+	}
+	if unsupported.Type != (unknownType{}) {
+		t.Errorf("got %v; want %v", unsupported.Type, unknownType{})
 	}
 }
@@ -0,0 +1,121 @@
+package interfaces
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// rewriteTypeArg rewrites qualified identifiers of a type argument, which
+// use full import paths, e.g. *example.com/user.User, into valid Go
+// expressions, e.g. *_0.User. The imports map is used to assign a
+// package name for each import path.
+func rewriteTypeArg(targ string, imports map[string]string) string {
+	var buf strings.Builder
+	for i := 0; i < len(targ); {
+		c := targ[i]
+		if !isIdentStart(c) {
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(targ) && isPathChar(targ[j]) {
+			j++
+		}
+		tok := targ[i:j]
+		if k := strings.LastIndexByte(tok, '.'); k != -1 {
+			name, ok := imports[tok[:k]]
+			if !ok {
+				name = "_" + strconv.Itoa(len(imports))
+				imports[tok[:k]] = name
+			}
+			tok = name + tok[k:]
+		}
+		buf.WriteString(tok)
+		i = j
+	}
+	return buf.String()
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isPathChar(c byte) bool {
+	return isIdentStart(c) || strings.IndexByte("./-~+", c) != -1
+}
+
+// typeArgPackages gives import paths of packages that the type arguments
+// refer to.
+func typeArgPackages(targs []string) []string {
+	imports := make(map[string]string)
+	for _, targ := range targs {
+		rewriteTypeArg(targ, imports)
+	}
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// evalTypeArgs evaluates type arguments of the query. Packages the type
+// arguments refer to are looked up in the dependencies of pkg first, so
+// the resulting types are identical to the ones pkg uses.
+func evalTypeArgs(q *Query, pkg *packages.Package, pkgs []*packages.Package) ([]types.Type, error) {
+	imports := make(map[string]string)
+	exprs := make([]string, len(q.TypeArgs))
+	for i, targ := range q.TypeArgs {
+		exprs[i] = rewriteTypeArg(targ, imports)
+	}
+	scope := types.NewPackage("query", "query")
+	for path, name := range imports {
+		imported := findPackage(pkg.Types, path, make(map[*types.Package]bool))
+		if imported == nil {
+			if p := lookupPackage(pkgs, path); p != nil {
+				imported = p.Types
+			}
+		}
+		if imported == nil {
+			return nil, fmt.Errorf("package %q of type arguments not found", path)
+		}
+		scope.Scope().Insert(types.NewPkgName(token.NoPos, scope, name, imported))
+	}
+	targs := make([]types.Type, len(exprs))
+	for i, expr := range exprs {
+		tv, err := types.Eval(token.NewFileSet(), scope, token.NoPos, expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type argument %q: %w", q.TypeArgs[i], err)
+		}
+		if !tv.IsType() {
+			return nil, fmt.Errorf("invalid type argument %q: not a type", q.TypeArgs[i])
+		}
+		targs[i] = tv.Type
+	}
+	return targs, nil
+}
+
+// findPackage looks up a package with the given import path in pkg and its
+// transitive imports.
+func findPackage(pkg *types.Package, path string, seen map[*types.Package]bool) *types.Package {
+	if pkg.Path() == path {
+		return pkg
+	}
+	seen[pkg] = true
+	for _, imp := range pkg.Imports() {
+		if seen[imp] {
+			continue
+		}
+		if found := findPackage(imp, path, seen); found != nil {
+			return found
+		}
+	}
+	return nil
+}